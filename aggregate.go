@@ -2,80 +2,281 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // AggregateOptions controls filtering applied before aggregation.
 type AggregateOptions struct {
-	Days       int    // 0 = all time
-	Project    string // empty = all projects
-	StatsCache *StatsCache
+	Days    int    // 0 = all time
+	Project string // empty = all projects
+	Model   string // case-insensitive model substring; empty = all models
+	// Location sets the time zone used to bucket records into days and
+	// months; nil means UTC.
+	Location     *time.Location
+	StatsCache   *StatsCache
+	SkippedPaths []string // unreadable paths from discovery, surfaced as an insight
+	GroupByWeek  bool     // also roll the daily slice up into ISO weeks
+	SkipClarity  bool     // leave report.Clarity nil and skip the extra clarity pass
+	NoDelta      bool     // don't total the preceding window for period deltas
+	// SinceFirstUse starts the daily (and weekly) trend at the first
+	// recorded message instead of padding earlier days with zeros, and drops
+	// the period comparison when the preceding window predates first use.
+	SinceFirstUse bool
+	// FillGaps makes the all-time daily trend cover every date from the
+	// first to the last record (at most maxFilledDays), with zero entries
+	// marked Filled for days without activity.
+	FillGaps bool
+	// ExcludeSubagents drops subagent files before parsing, so every total
+	// counts main-session records only.
+	ExcludeSubagents bool
+	Clarity          ClarityConfig
+
+	// Progress, if set, is called after each file is parsed with the number
+	// of files done, the total, and the bytes read so far.
+	Progress func(done, total int, bytesRead int64)
+
+	// SessionStream, if set, receives each SessionSummary as soon as the
+	// files for its session have been parsed (discovery lists them next to
+	// each other). Streamed sessions are not kept: report.Sessions and each
+	// project's Sessions stay empty, though SessionCount is still filled.
+	// The caller closes the channel after Aggregate returns.
+	SessionStream chan<- *SessionSummary
+
+	// RecordSink, if set, is called with every record that passes the
+	// filters, as it is counted. The record is not retained by Aggregate.
+	RecordSink func(*UsageRecord)
 }
 
 // Aggregate parses all discovered files and builds the full report.
 func Aggregate(files []FileInfo, opts AggregateOptions) *AggregatedReport {
+	start := time.Now()
+	if opts.ExcludeSubagents {
+		mainFiles := make([]FileInfo, 0, len(files))
+		for _, fi := range files {
+			if fi.Kind != KindSubagent {
+				mainFiles = append(mainFiles, fi)
+			}
+		}
+		files = mainFiles
+	}
 	report := &AggregatedReport{
+		GrandByType: map[string]*UsageTotals{
+			"main":     {},
+			"subagent": {},
+		},
 		ModelSummaries: make(map[string]*UsageTotals),
+		ModelFirstSeen: make(map[string]time.Time),
+		ModelLastSeen:  make(map[string]time.Time),
 		FilterDays:     opts.Days,
 		FilterProject:  opts.Project,
-		PeakHour:       -1,
+		NoSubagents:    opts.ExcludeSubagents,
+		SkippedPaths:   opts.SkippedPaths,
+	}
+
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
 	}
 
-	var cutoff time.Time
+	var cutoff, prevCutoff time.Time
 	if opts.Days > 0 {
 		cutoff = time.Now().UTC().AddDate(0, 0, -opts.Days)
+		prevCutoff = cutoff.AddDate(0, 0, -opts.Days)
+		if !opts.NoDelta {
+			report.Previous = &UsageTotals{}
+		}
 	}
 
-	// Per-slug and per-session accumulators
+	// Oldest record seen, before the date filter
+	var firstUse time.Time
+
+	// Filters are folded once; model IDs repeat across records, so each
+	// one's match is remembered.
+	projectFold, modelFold := foldCase(opts.Project), foldCase(opts.Model)
+	modelMatch := make(map[string]bool)
+
+	// Per-project and per-session accumulators
 	projectMap := make(map[string]*ProjectSummary)
 	sessionMap := make(map[string]*SessionSummary)
 	dailyMap := make(map[string]*UsageTotals)
-	// Track cwd per slug (derived from first record with non-empty cwd)
+	dailyModelMap := make(map[string]map[string]*UsageTotals) // date -> model -> totals
+	monthModelMap := make(map[[2]string]*UsageTotals)         // {month, model}
+	var hourly [24]UsageTotals
+	// Track cwd per project key (derived from first record with non-empty cwd)
 	slugCWD := make(map[string]string)
+	// User and tool_result counts, gathered during the same parse pass
+	tally := &MessageTally{Since: cutoff, Sessions: make(map[string]*MessageCounts)}
+	// Record count per git branch, per session
+	sessionBranches := make(map[string]map[string]int)
 
+	// File and session counts, straight from discovery
+	uniqueSessions := make(map[string]bool)
 	for _, fi := range files {
-		// Apply project filter
-		if opts.Project != "" {
-			slug := fi.ProjectSlug
-			cwd := slugCWD[slug]
-			if cwd == "" {
-				cwd = slugToPath(slug)
+		uniqueSessions[fi.SessionID] = true
+		if fi.Kind == KindSubagent {
+			report.SubagentFileCount++
+		} else {
+			report.MainFileCount++
+		}
+	}
+	report.UniqueSessionCount = len(uniqueSessions)
+
+	var filesDone int
+	var bytesRead int64
+
+	// finishSession fills the fields derived after parsing: message tallies,
+	// branches, duration and project name.
+	finishSession := func(sess *SessionSummary) {
+		if c, ok := tally.Sessions[sess.SessionID]; ok {
+			sess.UserMessageCount = c.UserMessages
+			sess.ToolResultCount = c.ToolResults
+			sess.ToolResultBytes = c.ToolResultBytes
+		}
+		sess.GitBranch, sess.Branches = dominantBranch(sessionBranches[sess.SessionID])
+		sess.BranchCount = len(sess.Branches)
+		sess.DurationSeconds = int64(sess.Duration().Seconds())
+		sess.MessageCount = sess.Totals.MessageCount
+		if proj, ok := projectMap[sess.projectKey]; ok && proj.Name != "" {
+			sess.ProjectName = proj.Name
+		} else if cwd := slugCWD[sess.projectKey]; cwd != "" {
+			sess.ProjectName = pathBase(cwd)
+		} else {
+			sess.ProjectName = pathBase(slugToPath(sess.ProjectSlug))
+		}
+	}
+
+	// With SessionStream, sessions touched since the last file-group change
+	// are sent and dropped once discovery moves on to another session.
+	var streamGroup string
+	pending := make(map[string]bool)
+	// Per-session sizes for the percentiles, kept for streamed sessions too.
+	var sessionTokens []int64
+	var sessionCosts []float64
+	flushSessions := func() {
+		for id := range pending {
+			sess := sessionMap[id]
+			finishSession(sess)
+			if proj, ok := projectMap[sess.projectKey]; ok {
+				proj.SessionCount++
+				if sess.SubagentTotals.TotalTokens() > 0 {
+					proj.SubagentCount++
+				}
 			}
-			projectName := filepath.Base(cwd)
-			if !containsCI(slug, opts.Project) && !containsCI(projectName, opts.Project) {
-				// We'll re-check after we have cwd — skip for now if no match
-				// (we may miss some; a second pass is not worth the complexity)
+			sessionTokens = append(sessionTokens, sess.CombinedTokens())
+			sessionCosts = append(sessionCosts, sess.Totals.CostUSD+sess.SubagentTotals.CostUSD)
+			opts.SessionStream <- sess
+			delete(sessionMap, id)
+			delete(sessionBranches, id)
+			delete(tally.Sessions, id)
+			delete(pending, id)
+		}
+	}
+
+	for _, fi := range files {
+		key := fi.ProjectKey()
+
+		if opts.SessionStream != nil {
+			if group := key + "\x00" + fi.SessionID; group != streamGroup {
+				flushSessions()
+				streamGroup = group
 			}
 		}
 
-		records, errs := ParseFile(fi.Path)
+		var fileTally *MessageTally
+		if fi.Kind == KindSession {
+			fileTally = tally
+		}
+		parseStart := time.Now()
+		records, errs, partial := ParseFile(fi.Path, fileTally)
+		report.Timings.Parse += time.Since(parseStart)
 		report.ParseErrors += errs
+		if partial {
+			report.PartialFiles++
+		}
+		if opts.Progress != nil {
+			filesDone++
+			if st, err := os.Stat(fi.Path); err == nil {
+				bytesRead += st.Size()
+			}
+			opts.Progress(filesDone, len(files), bytesRead)
+		}
 
 		for i, rec := range records {
 			// Capture cwd from first record
-			if rec.CWD != "" && slugCWD[fi.ProjectSlug] == "" {
-				slugCWD[fi.ProjectSlug] = rec.CWD
+			if rec.CWD != "" && slugCWD[key] == "" {
+				slugCWD[key] = rec.CWD
 			}
 			// Apply project filter using cwd
 			if opts.Project != "" && i == 0 {
-				cwd := slugCWD[fi.ProjectSlug]
-				name := filepath.Base(cwd)
-				if !containsCI(fi.ProjectSlug, opts.Project) && !containsCI(name, opts.Project) {
+				cwd := slugCWD[key]
+				name := pathBase(cwd)
+				if !containsFolded(fi.ProjectSlug, projectFold) && !containsFolded(name, projectFold) {
 					break // skip all records in this file
 				}
 			}
+			if opts.Model != "" {
+				match, ok := modelMatch[rec.Message.Model]
+				if !ok {
+					match = containsFolded(rec.Message.Model, modelFold)
+					modelMatch[rec.Message.Model] = match
+				}
+				if !match {
+					continue
+				}
+			}
+
+			if !rec.Timestamp.IsZero() && (firstUse.IsZero() || rec.Timestamp.Before(firstUse)) {
+				firstUse = rec.Timestamp
+			}
+			if m := rec.Message.Model; !rec.Timestamp.IsZero() {
+				if first, ok := report.ModelFirstSeen[m]; !ok || rec.Timestamp.Before(first) {
+					report.ModelFirstSeen[m] = rec.Timestamp
+				}
+				if rec.Timestamp.After(report.ModelLastSeen[m]) {
+					report.ModelLastSeen[m] = rec.Timestamp
+				}
+			}
 
 			// Apply date filter
 			if opts.Days > 0 && rec.Timestamp.Before(cutoff) {
+				// Records from the equally sized preceding window only feed
+				// the period-over-period totals.
+				if report.Previous != nil && !rec.Timestamp.Before(prevCutoff) {
+					report.Previous.Add(rec.Message.Usage, ComputeCostBreakdown(rec.Message.Model, rec.Message.Usage))
+				}
 				continue
 			}
 
 			model := rec.Message.Model
 			usage := rec.Message.Usage
-			cost := ComputeCost(model, usage)
+			cost := ComputeCostBreakdown(model, usage)
+
+			if opts.RecordSink != nil {
+				cwd := slugCWD[key]
+				if cwd == "" {
+					cwd = slugToPath(fi.ProjectSlug)
+				}
+				opts.RecordSink(&UsageRecord{
+					UUID:                     rec.UUID,
+					SessionID:                rec.SessionID,
+					Project:                  pathBase(cwd),
+					Model:                    model,
+					Timestamp:                rec.Timestamp,
+					InputTokens:              usage.InputTokens,
+					OutputTokens:             usage.OutputTokens,
+					CacheCreationInputTokens: usage.CacheCreationInputTokens,
+					CacheReadInputTokens:     usage.CacheReadInputTokens,
+					CostUSD:                  cost.Total(),
+				})
+			}
 
 			// Update date range
 			if report.DateFrom.IsZero() || rec.Timestamp.Before(report.DateFrom) {
@@ -87,6 +288,11 @@ func Aggregate(files []FileInfo, opts AggregateOptions) *AggregatedReport {
 
 			// Grand total
 			report.Grand.Add(usage, cost)
+			if fi.Kind == KindSubagent {
+				report.GrandByType["subagent"].Add(usage, cost)
+			} else {
+				report.GrandByType["main"].Add(usage, cost)
+			}
 
 			// Per-model
 			if _, ok := report.ModelSummaries[model]; !ok {
@@ -95,15 +301,21 @@ func Aggregate(files []FileInfo, opts AggregateOptions) *AggregatedReport {
 			report.ModelSummaries[model].Add(usage, cost)
 
 			// Per-project
-			proj := getOrCreateProject(projectMap, fi.ProjectSlug)
+			proj := getOrCreateProject(projectMap, key, fi)
 			proj.Totals.Add(usage, cost)
+			if rec.Timestamp.After(proj.LastActiveTime) {
+				proj.LastActiveTime = rec.Timestamp
+			}
 			if _, ok := proj.ModelBreakdown[model]; !ok {
 				proj.ModelBreakdown[model] = &UsageTotals{}
 			}
 			proj.ModelBreakdown[model].Add(usage, cost)
 
 			// Per-session
-			sess := getOrCreateSession(sessionMap, rec.SessionID, fi.ProjectSlug)
+			sess := getOrCreateSession(sessionMap, rec.SessionID, fi)
+			if opts.SessionStream != nil {
+				pending[sess.SessionID] = true
+			}
 			if fi.Kind == KindSubagent {
 				sess.SubagentTotals.Add(usage, cost)
 			} else {
@@ -113,6 +325,15 @@ func Aggregate(files []FileInfo, opts AggregateOptions) *AggregatedReport {
 				}
 				sess.ModelBreakdown[model].Add(usage, cost)
 			}
+			if rec.IsSidechain {
+				sess.SidechainTotals.Add(usage, cost)
+			}
+			if rec.GitBranch != "" {
+				if sessionBranches[sess.SessionID] == nil {
+					sessionBranches[sess.SessionID] = make(map[string]int)
+				}
+				sessionBranches[sess.SessionID][rec.GitBranch]++
+			}
 			// Track session time range
 			if !rec.Timestamp.IsZero() {
 				if sess.StartTime.IsZero() || rec.Timestamp.Before(sess.StartTime) {
@@ -124,37 +345,56 @@ func Aggregate(files []FileInfo, opts AggregateOptions) *AggregatedReport {
 			}
 
 			// Per-day
-			date := rec.Timestamp.UTC().Format("2006-01-02")
+			date := rec.Timestamp.In(loc).Format("2006-01-02")
 			if _, ok := dailyMap[date]; !ok {
 				dailyMap[date] = &UsageTotals{}
 			}
 			dailyMap[date].Add(usage, cost)
+			if dailyModelMap[date] == nil {
+				dailyModelMap[date] = make(map[string]*UsageTotals)
+			}
+			if _, ok := dailyModelMap[date][model]; !ok {
+				dailyModelMap[date][model] = &UsageTotals{}
+			}
+			dailyModelMap[date][model].Add(usage, cost)
+
+			hourly[rec.Timestamp.In(loc).Hour()].Add(usage, cost)
+
+			// Per-month, per-model
+			mk := [2]string{rec.Timestamp.In(loc).Format("2006-01"), model}
+			if _, ok := monthModelMap[mk]; !ok {
+				monthModelMap[mk] = &UsageTotals{}
+			}
+			monthModelMap[mk].Add(usage, cost)
 		}
 	}
 
+	if opts.SessionStream != nil {
+		flushSessions()
+	}
+
 	// Enrich project metadata from cwd
-	for slug, proj := range projectMap {
-		cwd := slugCWD[slug]
+	for key, proj := range projectMap {
+		cwd := slugCWD[key]
 		if cwd == "" {
-			cwd = slugToPath(slug)
+			cwd = slugToPath(proj.Slug)
 		}
 		proj.Path = cwd
-		proj.Name = filepath.Base(cwd)
+		proj.Name = pathBase(cwd)
 	}
 
-	// Enrich session metadata from project slugs
+	// Fold together projects reached through different (symlinked) paths.
+	// After this, several keys may point at the same *ProjectSummary.
+	mergeSymlinkedProjects(projectMap)
+
+	// Enrich session metadata from project slugs and message tallies
 	for _, sess := range sessionMap {
-		slug := sess.ProjectSlug
-		if proj, ok := projectMap[slug]; ok {
-			sess.ProjectName = proj.Name
-		} else {
-			sess.ProjectName = filepath.Base(slugToPath(slug))
-		}
+		finishSession(sess)
 	}
 
 	// Attach sessions to projects and count subagents
 	for _, sess := range sessionMap {
-		if proj, ok := projectMap[sess.ProjectSlug]; ok {
+		if proj, ok := projectMap[sess.projectKey]; ok {
 			proj.Sessions = append(proj.Sessions, sess)
 			proj.SessionCount++
 			if sess.SubagentTotals.TotalTokens() > 0 {
@@ -164,7 +404,12 @@ func Aggregate(files []FileInfo, opts AggregateOptions) *AggregatedReport {
 	}
 
 	// Build sorted slices
+	seenProjects := make(map[*ProjectSummary]bool)
 	for _, p := range projectMap {
+		if seenProjects[p] {
+			continue
+		}
+		seenProjects[p] = true
 		report.Projects = append(report.Projects, p)
 	}
 	sort.Slice(report.Projects, func(i, j int) bool {
@@ -173,76 +418,190 @@ func Aggregate(files []FileInfo, opts AggregateOptions) *AggregatedReport {
 
 	for _, s := range sessionMap {
 		report.Sessions = append(report.Sessions, s)
+		sessionTokens = append(sessionTokens, s.CombinedTokens())
+		sessionCosts = append(sessionCosts, s.Totals.CostUSD+s.SubagentTotals.CostUSD)
 	}
 	sort.Slice(report.Sessions, func(i, j int) bool {
 		return report.Sessions[i].CombinedTokens() > report.Sessions[j].CombinedTokens()
 	})
+	report.Percentiles, report.CostPercentiles = sessionPercentiles(sessionTokens, sessionCosts)
 
 	// Build daily summary slice (last N days or all)
-	report.Daily = buildDailySlice(dailyMap, opts.Days)
+	report.Daily = buildDailySlice(dailyMap, opts.Days, loc)
+	if opts.SinceFirstUse && !firstUse.IsZero() {
+		first := firstUse.In(loc).Format("2006-01-02")
+		if opts.Days == 0 {
+			// Every day from first use to today, not just the last 30 active.
+			today, _ := time.Parse("2006-01-02", time.Now().In(loc).Format("2006-01-02"))
+			start, _ := time.Parse("2006-01-02", first)
+			report.Daily = buildDailySlice(dailyMap, int(today.Sub(start).Hours()/24)+1, loc)
+		}
+		for len(report.Daily) > 0 && report.Daily[0].Date < first {
+			report.Daily = report.Daily[1:]
+		}
+		if report.Previous != nil && firstUse.After(prevCutoff) {
+			report.Previous = nil
+		}
+	}
+	if opts.FillGaps && opts.Days == 0 && !opts.SinceFirstUse && !report.DateFrom.IsZero() {
+		report.Daily = fillDailyGaps(dailyMap, report.DateFrom.In(loc), report.DateTo.In(loc))
+	}
+	report.AllDaily = buildDailySlice(dailyMap, -1, loc)
+	report.Hourly = make([]HourlySummary, 24)
+	for h := range hourly {
+		report.Hourly[h] = HourlySummary{Hour: h, Totals: hourly[h]}
+	}
+	for date, models := range dailyModelMap {
+		report.DailyByModel = append(report.DailyByModel, DailyModelSummary{Date: date, Models: models})
+	}
+	sort.Slice(report.DailyByModel, func(i, j int) bool {
+		return report.DailyByModel[i].Date < report.DailyByModel[j].Date
+	})
+
+	for mk, totals := range monthModelMap {
+		report.MonthlyModelBreakdown = append(report.MonthlyModelBreakdown,
+			MonthlyModelEntry{Month: mk[0], Model: mk[1], Totals: *totals})
+	}
+	sort.Slice(report.MonthlyModelBreakdown, func(i, j int) bool {
+		a, b := report.MonthlyModelBreakdown[i], report.MonthlyModelBreakdown[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		return a.Model < b.Model
+	})
+	if opts.GroupByWeek {
+		report.Weekly = buildWeeklySlice(report.Daily)
+	}
 
-	// Peak hour from stats-cache
+	// Peak hour and reconciliation from stats-cache
 	if opts.StatsCache != nil {
-		report.PeakHour = peakHour(opts.StatsCache.HourCounts)
+		if h := peakHour(opts.StatsCache.HourCounts); h >= 0 {
+			report.PeakHour = &h
+		}
+		report.Reconciliation = reconcileStatsCache(report.ModelSummaries, opts.StatsCache)
+		report.Reconciliation.Filtered = opts.Days > 0 || opts.Project != ""
+	}
+
+	// Compute prompt clarity metrics (before insights, which rank projects by it)
+	if !opts.SkipClarity {
+		clarityStart := time.Now()
+		report.Clarity = ComputeClarity(files, cutoff, opts.Clarity)
+		report.Timings.Clarity = time.Since(clarityStart)
 	}
 
 	// Generate insights
+	if !firstUse.IsZero() {
+		report.FirstUseDate = firstUse
+		report.DaysSinceFirstUse = int(time.Since(firstUse).Hours() / 24)
+	}
 	report.Insights = generateInsights(report, opts.StatsCache)
 
-	// Compute prompt clarity metrics
-	report.Clarity = ComputeClarity(files, cutoff)
+	report.Period = report.DateRange()
+	report.APIRequests = report.Grand.MessageCount
+	report.AvgCostPerRequest, report.AvgOutputPerRequest = report.Grand.PerRequest()
+	report.CacheSavingsUSD = cacheSavings(report.ModelSummaries)
 
+	report.Timings.Aggregate = time.Since(start) - report.Timings.Parse - report.Timings.Clarity
 	return report
 }
 
-func getOrCreateProject(m map[string]*ProjectSummary, slug string) *ProjectSummary {
-	if p, ok := m[slug]; ok {
+func getOrCreateProject(m map[string]*ProjectSummary, key string, fi FileInfo) *ProjectSummary {
+	if p, ok := m[key]; ok {
 		return p
 	}
 	p := &ProjectSummary{
-		Slug:           slug,
+		Slug:           fi.ProjectSlug,
+		DataDir:        fi.Root,
 		ModelBreakdown: make(map[string]*UsageTotals),
 	}
-	m[slug] = p
+	m[key] = p
 	return p
 }
 
-func getOrCreateSession(m map[string]*SessionSummary, sessionID, projectSlug string) *SessionSummary {
+// mergeSymlinkedProjects resolves each project's path with filepath.EvalSymlinks
+// and merges projects whose resolved paths match into a single summary. The
+// alphabetically-first key wins; the other keys are re-pointed at it.
+// Paths that cannot be resolved (e.g. deleted directories) are left alone.
+func mergeSymlinkedProjects(projectMap map[string]*ProjectSummary) {
+	keys := make([]string, 0, len(projectMap))
+	for key := range projectMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	byResolved := make(map[string]*ProjectSummary)
+	for _, key := range keys {
+		proj := projectMap[key]
+		if proj.Path == "" {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(proj.Path)
+		if err != nil {
+			continue
+		}
+		// Projects from separate data directories stay apart unless merged.
+		resolved = proj.DataDir + "\x00" + resolved
+		canon, ok := byResolved[resolved]
+		if !ok {
+			byResolved[resolved] = proj
+			continue
+		}
+		canon.Totals.Merge(proj.Totals)
+		canon.SessionCount += proj.SessionCount // nonzero only for streamed sessions
+		if proj.LastActiveTime.After(canon.LastActiveTime) {
+			canon.LastActiveTime = proj.LastActiveTime
+		}
+		canon.SubagentCount += proj.SubagentCount
+		for model, totals := range proj.ModelBreakdown {
+			if _, ok := canon.ModelBreakdown[model]; !ok {
+				canon.ModelBreakdown[model] = &UsageTotals{}
+			}
+			canon.ModelBreakdown[model].Merge(*totals)
+		}
+		projectMap[key] = canon
+	}
+}
+
+func getOrCreateSession(m map[string]*SessionSummary, sessionID string, fi FileInfo) *SessionSummary {
 	if s, ok := m[sessionID]; ok {
 		return s
 	}
 	s := &SessionSummary{
 		SessionID:      sessionID,
-		ProjectSlug:    projectSlug,
+		ProjectSlug:    fi.ProjectSlug,
 		ModelBreakdown: make(map[string]*UsageTotals),
+		projectKey:     fi.ProjectKey(),
 	}
 	m[sessionID] = s
 	return s
 }
 
-func buildDailySlice(dailyMap map[string]*UsageTotals, days int) []DailySummary {
+// buildDailySlice returns daily summaries sorted by date. days > 0 yields
+// exactly that many trailing days (zero-filled); days == 0 keeps the last 30
+// active days; days < 0 keeps every active day. Dates are days in loc.
+func buildDailySlice(dailyMap map[string]*UsageTotals, days int, loc *time.Location) []DailySummary {
 	var result []DailySummary
 
 	if days > 0 {
 		// Fill in all days in range, including zero-token days
-		now := time.Now().UTC()
+		now := time.Now().In(loc)
 		for i := days - 1; i >= 0; i-- {
 			date := now.AddDate(0, 0, -i).Format("2006-01-02")
-			ds := DailySummary{Date: date}
-			if totals, ok := dailyMap[date]; ok {
-				ds.Totals = *totals
+			var totals UsageTotals
+			if t, ok := dailyMap[date]; ok {
+				totals = *t
 			}
-			result = append(result, ds)
+			result = append(result, newDailySummary(date, totals))
 		}
 	} else {
 		for date, totals := range dailyMap {
-			result = append(result, DailySummary{Date: date, Totals: *totals})
+			result = append(result, newDailySummary(date, *totals))
 		}
 		sort.Slice(result, func(i, j int) bool {
 			return result[i].Date < result[j].Date
 		})
 		// Keep last 30 days for display if all-time
-		if len(result) > 30 {
+		if days == 0 && len(result) > 30 {
 			result = result[len(result)-30:]
 		}
 	}
@@ -250,6 +609,160 @@ func buildDailySlice(dailyMap map[string]*UsageTotals, days int) []DailySummary
 	return result
 }
 
+// dominantBranch returns the most frequent branch (ties broken alphabetically)
+// and every distinct branch, sorted.
+func dominantBranch(counts map[string]int) (string, []string) {
+	best, bestCount := "", 0
+	var all []string
+	for branch, n := range counts {
+		all = append(all, branch)
+		if n > bestCount || (n == bestCount && branch < best) {
+			best, bestCount = branch, n
+		}
+	}
+	sort.Strings(all)
+	return best, all
+}
+
+// buildWeeklySlice rolls daily summaries up into ISO weeks, keeping the
+// per-weekday token split for the intra-week sparkline.
+func buildWeeklySlice(daily []DailySummary) []WeeklySummary {
+	var result []WeeklySummary
+	index := make(map[string]int)
+	for _, d := range daily {
+		t, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			continue
+		}
+		year, week := t.ISOWeek()
+		label := fmt.Sprintf("W%02d %d", week, year)
+		i, ok := index[label]
+		if !ok {
+			i = len(result)
+			index[label] = i
+			result = append(result, WeeklySummary{WeekLabel: label})
+		}
+		result[i].Totals.Merge(d.Totals)
+		result[i].DayTokens[(int(t.Weekday())+6)%7] += d.Totals.TotalTokens()
+	}
+	return result
+}
+
+// maxFilledDays caps the --fill-daily-gaps trend to the latest year.
+const maxFilledDays = 365
+
+// fillDailyGaps returns one summary per calendar date from from to to, both
+// already in the report's zone, keeping only the last maxFilledDays. Dates
+// missing from dailyMap get zero totals and Filled set.
+func fillDailyGaps(dailyMap map[string]*UsageTotals, from, to time.Time) []DailySummary {
+	// Step through plain dates in UTC so DST changes can't skip or repeat a day.
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	if earliest := end.AddDate(0, 0, -(maxFilledDays - 1)); start.Before(earliest) {
+		start = earliest
+	}
+	var result []DailySummary
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		if t, ok := dailyMap[date]; ok {
+			result = append(result, newDailySummary(date, *t))
+			continue
+		}
+		day := newDailySummary(date, UsageTotals{})
+		day.Filled = true
+		result = append(result, day)
+	}
+	return result
+}
+
+// newDailySummary builds a DailySummary with its convenience fields filled in.
+func newDailySummary(date string, totals UsageTotals) DailySummary {
+	return DailySummary{
+		Date:             date,
+		Totals:           totals,
+		DailyCostUSD:     totals.CostUSD,
+		DailyTotalTokens: totals.TotalTokens(),
+	}
+}
+
+// reconcileStatsCache lines up computed per-model totals against the ones
+// recorded in stats-cache.json.
+func reconcileStatsCache(models map[string]*UsageTotals, sc *StatsCache) *Reconciliation {
+	ids := make(map[string]bool, len(models)+len(sc.ModelUsage))
+	for m := range models {
+		ids[m] = true
+	}
+	for m := range sc.ModelUsage {
+		ids[m] = true
+	}
+
+	rec := &Reconciliation{}
+	for m := range ids {
+		mr := ModelReconciliation{Model: m}
+		if t, ok := models[m]; ok {
+			mr.Tokens = t.TotalTokens()
+			mr.CostUSD = t.CostUSD
+		}
+		if su, ok := sc.ModelUsage[m]; ok {
+			mr.StatsTokens = su.InputTokens + su.OutputTokens + su.CacheCreationInputTokens + su.CacheReadInputTokens
+			mr.StatsCostUSD = su.CostUSD
+		}
+		mr.TokensDiffPct = diffPct(float64(mr.Tokens), float64(mr.StatsTokens))
+		mr.CostDiffPct = diffPct(mr.CostUSD, mr.StatsCostUSD)
+		rec.Models = append(rec.Models, mr)
+
+		rec.Total.Tokens += mr.Tokens
+		rec.Total.StatsTokens += mr.StatsTokens
+		rec.Total.CostUSD += mr.CostUSD
+		rec.Total.StatsCostUSD += mr.StatsCostUSD
+	}
+	sort.Slice(rec.Models, func(i, j int) bool {
+		return rec.Models[i].Model < rec.Models[j].Model
+	})
+	rec.Total.TokensDiffPct = diffPct(float64(rec.Total.Tokens), float64(rec.Total.StatsTokens))
+	rec.Total.CostDiffPct = diffPct(rec.Total.CostUSD, rec.Total.StatsCostUSD)
+	return rec
+}
+
+// sessionPercentiles returns nearest-rank P50/P90/P99 of the per-session
+// token counts and costs. Both slices are sorted in place.
+func sessionPercentiles(tokens []int64, costs []float64) (Percentiles, CostPercentiles) {
+	n := len(tokens)
+	if n == 0 {
+		return Percentiles{}, CostPercentiles{}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	sort.Float64s(costs)
+	rank := func(p float64) int {
+		return int(math.Ceil(p/100*float64(n))) - 1
+	}
+	return Percentiles{P50: tokens[rank(50)], P90: tokens[rank(90)], P99: tokens[rank(99)]},
+		CostPercentiles{P50: costs[rank(50)], P90: costs[rank(90)], P99: costs[rank(99)]}
+}
+
+// cacheSavings sums, per model, the cache read tokens times the gap between
+// the model's input and cache read rates. Unknown models contribute nothing.
+func cacheSavings(models map[string]*UsageTotals) float64 {
+	var total float64
+	for model, t := range models {
+		p, ok := LookupPricing(model)
+		if !ok {
+			continue
+		}
+		total += float64(t.CacheReadInputTokens) / 1_000_000 * (p.InputPerMTok - p.CacheReadPerMTok)
+	}
+	return total
+}
+
+// diffPct returns (got - want) / want as a percentage, or nil if want is zero.
+func diffPct(got, want float64) *float64 {
+	if want == 0 {
+		return nil
+	}
+	d := (got - want) / want * 100
+	return &d
+}
+
 func peakHour(hourCounts map[string]int) int {
 	if len(hourCounts) == 0 {
 		return -1
@@ -306,8 +819,8 @@ func generateInsights(r *AggregatedReport, sc *StatsCache) []Insight {
 
 	// 3. Subagent overhead
 	var subagentTotal int64
-	for _, sess := range r.Sessions {
-		subagentTotal += sess.SubagentTotals.TotalTokens()
+	if sub := r.GrandByType["subagent"]; sub != nil {
+		subagentTotal = sub.TotalTokens()
 	}
 	if subagentTotal > 0 && r.Grand.TotalTokens() > 0 {
 		overheadPct := float64(subagentTotal) / float64(r.Grand.TotalTokens()) * 100
@@ -317,15 +830,39 @@ func generateInsights(r *AggregatedReport, sc *StatsCache) []Insight {
 		})
 	}
 
-	// 4. Peak hour
-	if r.PeakHour >= 0 {
+	// 4. Branch switching
+	var multiTokens, singleTokens int64
+	var multiCount, singleCount int
+	for _, sess := range r.Sessions {
+		switch {
+		case sess.BranchCount > 1:
+			multiTokens += sess.CombinedTokens()
+			multiCount++
+		case sess.BranchCount == 1:
+			singleTokens += sess.CombinedTokens()
+			singleCount++
+		}
+	}
+	if multiCount > 0 && singleCount > 0 {
+		multiAvg := float64(multiTokens) / float64(multiCount)
+		singleAvg := float64(singleTokens) / float64(singleCount)
+		if multiAvg > singleAvg {
+			insights = append(insights, Insight{
+				Severity: "info",
+				Message:  fmt.Sprintf("Sessions that switched git branches averaged %s tokens vs %s for single-branch sessions (%.1f×). Starting a fresh session per branch keeps context focused.", fmtTokensInt(int64(multiAvg)), fmtTokensInt(int64(singleAvg)), multiAvg/singleAvg),
+			})
+		}
+	}
+
+	// 5. Peak hour
+	if r.PeakHour != nil {
 		insights = append(insights, Insight{
 			Severity: "info",
-			Message:  fmt.Sprintf("Your peak usage hour is %02d:00–%02d:00 local time.", r.PeakHour, r.PeakHour+1),
+			Message:  fmt.Sprintf("Your peak usage hour is %02d:00–%02d:00 local time.", *r.PeakHour, *r.PeakHour+1),
 		})
 	}
 
-	// 5. Unrecognized models
+	// 6. Unrecognized models
 	for model := range r.ModelSummaries {
 		if _, ok := LookupPricing(model); !ok {
 			insights = append(insights, Insight{
@@ -335,7 +872,7 @@ func generateInsights(r *AggregatedReport, sc *StatsCache) []Insight {
 		}
 	}
 
-	// 6. Parse errors
+	// 7. Parse errors
 	if r.ParseErrors > 0 {
 		insights = append(insights, Insight{
 			Severity: "warn",
@@ -343,43 +880,201 @@ func generateInsights(r *AggregatedReport, sc *StatsCache) []Insight {
 		})
 	}
 
+	// 8. Files caught mid-write by a live session
+	if n := r.PartialFiles; n > 0 {
+		msg := "1 session file is currently being written (partial data included)."
+		if n > 1 {
+			msg = fmt.Sprintf("%d session files are currently being written (partial data included).", n)
+		}
+		insights = append(insights, Insight{
+			Severity: "info",
+			Message:  msg,
+		})
+	}
+
+	// 9. Unreadable paths
+	if n := len(r.SkippedPaths); n > 0 {
+		msg := fmt.Sprintf("%d path(s) under projects/ were skipped (permission denied), so totals are incomplete: %s", n, r.SkippedPaths[0])
+		if n > 1 {
+			msg += fmt.Sprintf(" and %d more", n-1)
+		}
+		insights = append(insights, Insight{
+			Severity: "warn",
+			Message:  msg + ".",
+		})
+	}
+
+	// 10. Weakest project prompt clarity. "N× worse" compares each score's
+	// distance from a perfect 100.
+	if cl := r.Clarity; cl != nil && len(cl.ScoreByProject) >= 2 {
+		worst := cl.ScoreByProject[0]
+		gap, avgGap := 100-worst.Score, 100-cl.Overall.Score
+		if avgGap > 0 && gap/avgGap >= 1.5 {
+			insights = append(insights, Insight{
+				Severity: "info",
+				Message: fmt.Sprintf("Project %s has the weakest prompt clarity (score %.0f) — %s× worse than your average.",
+					worst.ProjectName, worst.Score, strconv.FormatFloat(math.Round(gap/avgGap*10)/10, 'f', -1, 64)),
+			})
+		}
+	}
+
+	// 11. Projects with very low front-loading
+	if cl := r.Clarity; cl != nil {
+		var keys []string
+		for key, ratio := range cl.FrontLoadByProject {
+			if ratio < 0.30 {
+				keys = append(keys, key)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if a, b := cl.ProjectName(keys[i]), cl.ProjectName(keys[j]); a != b {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys {
+			insights = append(insights, Insight{
+				Severity: "info",
+				Message:  fmt.Sprintf("Project %s has very low front-loading (%.0f%%) — paste all context upfront.", cl.ProjectName(key), cl.FrontLoadByProject[key]*100),
+			})
+		}
+	}
+
+	// 12. Projects untouched for over 60 days
+	idleCutoff := time.Now().AddDate(0, 0, -60)
+	var idle []string
+	for _, proj := range r.Projects {
+		if !proj.LastActiveTime.IsZero() && proj.LastActiveTime.Before(idleCutoff) {
+			idle = append(idle, proj.Name)
+		}
+	}
+	if len(idle) > 0 {
+		sort.Strings(idle)
+		insights = append(insights, Insight{
+			Severity: "info",
+			Message:  fmt.Sprintf("%d project(s) not touched in over 60 days: %s.", len(idle), strings.Join(idle, ", ")),
+		})
+	}
+
+	// 13. Models not used for a month (migrated off, or retired)
+	staleCutoff := time.Now().AddDate(0, 0, -30)
+	var stale []string
+	for model, last := range r.ModelLastSeen {
+		if last.Before(staleCutoff) {
+			stale = append(stale, fmt.Sprintf("%s (last %s)", model, last.Local().Format("2006-01-02")))
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		insights = append(insights, Insight{
+			Severity: "info",
+			Message:  fmt.Sprintf("%d model(s) not used in over 30 days: %s.", len(stale), strings.Join(stale, ", ")),
+		})
+	}
+
+	// 14. First week of use
+	if !r.FirstUseDate.IsZero() && r.DaysSinceFirstUse < 7 {
+		insights = append(insights, Insight{
+			Severity: "info",
+			Message:  "You're new! Cache efficiency typically improves after 2 weeks of regular use.",
+		})
+	}
+
+	// 15. Verbose replies, often the model over-explaining a vague request
+	if cl := r.Clarity; cl != nil && cl.AvgResponseLength > maxAvgResponseLength {
+		insights = append(insights, Insight{
+			Severity: "info",
+			Message: fmt.Sprintf("Model responses average %s chars — consider adding 'be concise' to CLAUDE.md.",
+				fmtInt(int64(math.Round(cl.AvgResponseLength)))),
+		})
+	}
+
 	return insights
 }
 
-// containsCI is a case-insensitive substring check.
-func containsCI(s, sub string) bool {
-	if sub == "" {
-		return true
-	}
-	return len(s) >= len(sub) && func() bool {
-		sLower := toLower(s)
-		subLower := toLower(sub)
-		for i := 0; i <= len(sLower)-len(subLower); i++ {
-			if sLower[i:i+len(subLower)] == subLower {
-				return true
-			}
+// maxAvgResponseLength is the mean assistant reply length, in characters,
+// above which the verbose-responses insight fires.
+const maxAvgResponseLength = 2000
+
+// severityRank orders insight severities for --min-severity filtering.
+// "good" ranks with "info": both are non-actionable.
+func severityRank(sev string) int {
+	if sev == "warn" {
+		return 1
+	}
+	return 0
+}
+
+// FilterInsights returns the insights at or above minSeverity and how many
+// were dropped. The input slice is not modified.
+func FilterInsights(insights []Insight, minSeverity string) (kept []Insight, suppressed int) {
+	minRank := severityRank(minSeverity)
+	for _, ins := range insights {
+		if severityRank(ins.Severity) >= minRank {
+			kept = append(kept, ins)
+		} else {
+			suppressed++
 		}
-		return false
-	}()
+	}
+	return kept, suppressed
+}
+
+// containsCI is a case-insensitive substring check, folding case the way
+// strings.EqualFold does (so "É" matches "é" and "K" the Kelvin sign).
+func containsCI(s, sub string) bool {
+	return containsFolded(s, foldCase(sub))
 }
 
-func toLower(s string) string {
-	b := []byte(s)
-	for i, c := range b {
-		if c >= 'A' && c <= 'Z' {
-			b[i] = c + 32
+// containsFolded reports whether s contains folded, a string already passed
+// through foldCase. Hoisting that out of loops saves folding the filter on
+// every call.
+func containsFolded(s, folded string) bool {
+	return folded == "" || strings.Contains(foldCase(s), folded)
+}
+
+// foldCase maps every rune of s to a canonical member of its simple case
+// folding orbit (the smallest), so two strings are equal under
+// strings.EqualFold exactly when their foldCase results are equal.
+func foldCase(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
 		}
 	}
-	return string(b)
+	if ascii {
+		// The smallest of {a, A} is A; 'k' and 's' orbits add non-ASCII
+		// runes, but those are larger than 'K' and 'S'.
+		return strings.ToUpper(s)
+	}
+	return strings.Map(func(r rune) rune {
+		canon := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			canon = min(canon, f)
+		}
+		return canon
+	}, s)
 }
 
-// fmtTokensInt formats tokens for use in insight messages.
+// fmtTokensInt formats tokens for use in insight messages, abbreviated to
+// one decimal (1.2K / 3.4M / 1.1B / 2.0T) with half-up rounding.
 func fmtTokensInt(n int64) string {
-	if n >= 1_000_000 {
-		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	if n < 0 {
+		return "-" + fmtTokensInt(-n)
+	}
+	if n < 1_000 {
+		return fmt.Sprintf("%d", n)
 	}
-	if n >= 1_000 {
-		return fmt.Sprintf("%.1fK", float64(n)/1_000)
+	units := []string{"K", "M", "B", "T"}
+	v := float64(n)
+	for i, unit := range units {
+		v /= 1_000
+		rounded := math.Floor(v*10+0.5) / 10
+		// 999,950 rounds to 1000.0K; carry into the next unit instead.
+		if rounded < 1_000 || i == len(units)-1 {
+			return fmt.Sprintf("%.1f%s", rounded, unit)
+		}
 	}
-	return fmt.Sprintf("%d", n)
+	return ""
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// twoPromptSession writes a session with two prompts and two replies
+// starting at start, and returns its FileInfo.
+func twoPromptSession(t *testing.T, dir, slug, session string, start time.Time) FileInfo {
+	u := TokenUsage{InputTokens: 100, OutputTokens: 200, CacheReadInputTokens: 1000}
+	return writeSession(t, dir, slug, session,
+		userText(t, session, ts(start), "Add a --verbose flag to the CLI that logs each file as it is parsed"),
+		assistantText(t, session, ts(start.Add(time.Minute)), "claude-sonnet-4-5", "Done, the flag is wired up.", u),
+		userText(t, session, ts(start.Add(2*time.Minute)), "Also mention it in the README"),
+		assistantText(t, session, ts(start.Add(3*time.Minute)), "claude-sonnet-4-5", "Added a line to the README.", u),
+	)
+}
+
+func TestAggregateClarity(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	files := []FileInfo{
+		twoPromptSession(t, dir, "-work-api", testSession, start),
+		twoPromptSession(t, dir, "-work-api", testOther, start.Add(time.Hour)),
+	}
+
+	r := Aggregate(files, AggregateOptions{})
+	if r.Clarity == nil {
+		t.Fatal("Aggregate left Clarity nil")
+	}
+	if r.Clarity.SessionCount != 2 || r.Clarity.ScoredSessionCount != 2 {
+		t.Errorf("SessionCount, ScoredSessionCount = %d, %d; want 2, 2", r.Clarity.SessionCount, r.Clarity.ScoredSessionCount)
+	}
+	if r.Grand.MessageCount != 4 {
+		t.Errorf("Grand.MessageCount = %d, want 4", r.Grand.MessageCount)
+	}
+
+	if r := Aggregate(files, AggregateOptions{SkipClarity: true}); r.Clarity != nil {
+		t.Errorf("SkipClarity: Clarity = %+v, want nil", r.Clarity)
+	}
+}
+
+// Records either side of midnight UTC land on different days depending on
+// the zone the report buckets by.
+func TestAggregateDailyTimeZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	dir := t.TempDir()
+	u := TokenUsage{InputTokens: 10, OutputTokens: 10}
+	reply := func(at string) string {
+		return testRecord(t, "assistant", testSession, at, []map[string]any{{"type": "text", "text": "ok"}}, &u, "claude-sonnet-4-5")
+	}
+	files := []FileInfo{writeSession(t, dir, "-work-api", testSession,
+		reply("2026-01-31T22:30:00.000Z"), // Jan 31 23:30 in Berlin
+		reply("2026-01-31T23:30:00.000Z"), // Feb 01 00:30 in Berlin
+		reply("2026-02-01T00:30:00.000Z"), // Feb 01 01:30 in Berlin
+	)}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want map[string]int64 // date -> messages
+		hour int              // hour of the first record
+	}{
+		{"UTC", nil, map[string]int64{"2026-01-31": 2, "2026-02-01": 1}, 22},
+		{"Europe/Berlin", berlin, map[string]int64{"2026-01-31": 1, "2026-02-01": 2}, 23},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Aggregate(files, AggregateOptions{Location: tt.loc, SkipClarity: true})
+			got := make(map[string]int64)
+			for _, d := range r.AllDaily {
+				got[d.Date] = d.Totals.MessageCount
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("AllDaily = %v, want %v", got, tt.want)
+			}
+			for date, n := range tt.want {
+				if got[date] != n {
+					t.Errorf("%s: %d messages, want %d", date, got[date], n)
+				}
+			}
+			if n := r.Hourly[tt.hour].Totals.MessageCount; n != 1 {
+				t.Errorf("Hourly[%d] has %d messages, want 1", tt.hour, n)
+			}
+		})
+	}
+}
+
+func TestContainsCI(t *testing.T) {
+	tests := []struct {
+		s, sub string
+		want   bool
+	}{
+		{"my-api-server", "API", true},
+		{"my-api-server", "", true},
+		{"", "api", false},
+		{"my-api-server", "web", false},
+		{"Café-Backend", "CAFÉ", true},
+		{"café-backend", "Café", true},
+		{"Straße", "STRASSE", false}, // ß only full-folds to "ss"
+		{"Straße", "STRAẞE", true},   // capital sharp s is in ß's simple orbit
+		{"kelvin", "Kelvin", true},
+		{"Kelvin", "KELVIN", true},
+		{"Проект-Альфа", "проект", true},
+		{"ΣΊΣΥΦΟΣ", "σίσυφος", true},
+		{"ΣΊΣΥΦΟΣ", "σίσυφοσ", true}, // final and medial sigma fold together
+		{"数据平台", "平台", true},
+		{"数据平台", "平臺", false},
+	}
+	for _, tt := range tests {
+		if got := containsCI(tt.s, tt.sub); got != tt.want {
+			t.Errorf("containsCI(%q, %q) = %v, want %v", tt.s, tt.sub, got, tt.want)
+		}
+	}
+}
+
+// foldCase(a) == foldCase(b) must agree with strings.EqualFold.
+func TestFoldCase(t *testing.T) {
+	words := []string{
+		"api", "API", "Api", "kelvin", "Kelvin", "KELVIN", "ſ", "s", "S",
+		"Café", "CAFÉ", "cafe", "Straße", "STRAẞE", "STRASSE",
+		"проект", "ПРОЕКТ", "σίσυφος", "ΣΊΣΥΦΟΣ", "σίσυφοσ", "µ", "μ", "Μ", "数据",
+	}
+	for _, a := range words {
+		for _, b := range words {
+			if got, want := foldCase(a) == foldCase(b), strings.EqualFold(a, b); got != want {
+				t.Errorf("foldCase(%q) == foldCase(%q) is %v, strings.EqualFold says %v", a, b, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkContainsCI(b *testing.B) {
+	for _, bm := range []struct{ name, s, sub string }{
+		{"ascii", "-Users-someone-work-token-analyzer-server", "ANALYZER"},
+		{"unicode", "-Users-someone-work-Проект-Café-Backend", "café"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				containsCI(bm.s, bm.sub)
+			}
+		})
+	}
+}
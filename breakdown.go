@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// breakdownKinds are the views accepted by --breakdown.
+var breakdownKinds = []string{"date", "model", "project", "session"}
+
+// PrintBreakdown writes one flat table for --breakdown: a header row, then one
+// row per date, model, project or session. Numbers are unformatted (no commas
+// or currency signs) so the output can go straight into sort, cut or awk.
+// With aligned set, columns are padded for reading in a terminal; otherwise
+// they are tab-separated.
+func PrintBreakdown(w io.Writer, r *AggregatedReport, kind string, aligned bool) error {
+	rows, err := breakdownRows(r, kind)
+	if err != nil {
+		return err
+	}
+
+	if !aligned {
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// WriteBreakdownCSV writes the same table as PrintBreakdown as CSV.
+func WriteBreakdownCSV(w io.Writer, r *AggregatedReport, kind string) error {
+	rows, err := breakdownRows(r, kind)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// breakdownRows builds the header and data rows of one breakdown table.
+func breakdownRows(r *AggregatedReport, kind string) ([][]string, error) {
+	var rows [][]string
+	switch kind {
+	case "date":
+		rows = append(rows, []string{"date", "tokens", "cost_usd", "requests"})
+		for _, d := range r.AllDaily {
+			rows = append(rows, []string{
+				d.Date,
+				fmt.Sprint(d.Totals.TotalTokens()),
+				fmt.Sprintf("%.4f", d.Totals.CostUSD),
+				fmt.Sprint(d.Totals.MessageCount),
+			})
+		}
+
+	case "model":
+		rows = append(rows, []string{"model", "input", "output", "cache_write", "cache_read", "tokens", "cost_usd"})
+		models := make([]string, 0, len(r.ModelSummaries))
+		for m := range r.ModelSummaries {
+			models = append(models, m)
+		}
+		sort.Slice(models, func(i, j int) bool {
+			return r.ModelSummaries[models[i]].CostUSD > r.ModelSummaries[models[j]].CostUSD
+		})
+		for _, m := range models {
+			t := r.ModelSummaries[m]
+			rows = append(rows, []string{
+				m,
+				fmt.Sprint(t.InputTokens),
+				fmt.Sprint(t.OutputTokens),
+				fmt.Sprint(t.CacheCreationInputTokens),
+				fmt.Sprint(t.CacheReadInputTokens),
+				fmt.Sprint(t.TotalTokens()),
+				fmt.Sprintf("%.4f", t.CostUSD),
+			})
+		}
+
+	case "project":
+		rows = append(rows, []string{"project", "tokens", "cost_usd", "sessions", "path"})
+		for _, proj := range r.Projects {
+			rows = append(rows, []string{
+				proj.Name,
+				fmt.Sprint(proj.Totals.TotalTokens()),
+				fmt.Sprintf("%.4f", proj.Totals.CostUSD),
+				fmt.Sprint(proj.SessionCount),
+				proj.Path,
+			})
+		}
+
+	case "session":
+		rows = append(rows, []string{"session", "project", "started", "tokens", "cost_usd"})
+		for _, sess := range r.Sessions {
+			started := ""
+			if !sess.StartTime.IsZero() {
+				started = sess.StartTime.UTC().Format("2006-01-02T15:04:05Z")
+			}
+			rows = append(rows, []string{
+				sess.SessionID,
+				sess.ProjectName,
+				started,
+				fmt.Sprint(sess.CombinedTokens()),
+				fmt.Sprintf("%.4f", sess.Totals.CostUSD+sess.SubagentTotals.CostUSD),
+			})
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown breakdown %q (want one of: %s)", kind, strings.Join(breakdownKinds, ", "))
+	}
+	return rows, nil
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // ---- Signal lists ----
@@ -88,6 +91,25 @@ var clarificationSignals = []string{
 
 // ---- Text extraction ----
 
+// maxTextLen caps the message text clarity analysis keeps for signal
+// matching. Signals only look at the start of a message, so this just keeps
+// huge pastes (base64 images, file dumps) out of memory; lengths and word
+// counts are taken from the full text before it is capped.
+const maxTextLen = 10_000
+
+// capText truncates s to at most maxTextLen bytes on a rune boundary. The
+// result is copied so the oversized original can be garbage-collected.
+func capText(s string) string {
+	if len(s) <= maxTextLen {
+		return s
+	}
+	cut := maxTextLen
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return strings.Clone(s[:cut])
+}
+
 // extractText pulls plain text from message.content.
 // Handles string content and []contentBlock arrays.
 // Skips tool_result and tool_use blocks.
@@ -123,6 +145,26 @@ func extractText(raw json.RawMessage) string {
 	return ""
 }
 
+// hasToolUse reports whether message.content is a block array containing
+// at least one tool_use block.
+func hasToolUse(raw json.RawMessage) bool {
+	if len(raw) == 0 || raw[0] != '[' {
+		return false
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return false
+	}
+	for _, b := range blocks {
+		if b.Type == "tool_use" {
+			return true
+		}
+	}
+	return false
+}
+
 // isRealUserMessage returns true for genuine user prompts (not tool results).
 func isRealUserMessage(rec MessageRecord) bool {
 	if rec.Type != "user" {
@@ -183,13 +225,45 @@ func detectCorrectionType(text string) (string, bool) {
 }
 
 func hasClarificationSignal(text string) bool {
+	return len(clarificationPhrases(text)) > 0
+}
+
+// clarificationPhrases returns every clarificationSignals phrase in text.
+func clarificationPhrases(text string) []string {
 	lower := strings.ToLower(text)
+	var found []string
 	for _, sig := range clarificationSignals {
 		if strings.Contains(lower, sig) {
-			return true
+			found = append(found, sig)
 		}
 	}
-	return false
+	return found
+}
+
+// maxClarificationPhrases caps ClarityReport.ClarificationPhraseFrequency.
+const maxClarificationPhrases = 10
+
+// topPhrases keeps the n most frequent phrases in counts, ties broken
+// alphabetically so the cut is stable.
+func topPhrases(counts map[string]int, n int) map[string]int {
+	if len(counts) <= n {
+		return counts
+	}
+	phrases := make([]string, 0, len(counts))
+	for ph := range counts {
+		phrases = append(phrases, ph)
+	}
+	sort.Slice(phrases, func(i, j int) bool {
+		if counts[phrases[i]] != counts[phrases[j]] {
+			return counts[phrases[i]] > counts[phrases[j]]
+		}
+		return phrases[i] < phrases[j]
+	})
+	top := make(map[string]int, n)
+	for _, ph := range phrases[:n] {
+		top[ph] = counts[ph]
+	}
+	return top
 }
 
 // mondayOf returns the Monday (UTC) of the week containing t.
@@ -219,20 +293,46 @@ func mondayOf(t time.Time) time.Time {
 // ---- Per-session state ----
 
 type sessionClarityState struct {
-	userMessages       []string
-	firstAssistantText string
+	userMessageLens    []int  // length of each real prompt, in order
+	firstMessageWords  int    // word count of the first prompt
+	firstAssistantText string // capped at maxTextLen
 	hadClarification   bool
+	clarifyingPhrases  []string // clarificationSignals found in firstAssistantText
+	responseChars      int      // length of all assistant text replies, in runes
+	responseCount      int
+	toolTurnCount      int // user records that only return tool results
 	correctionCount    int
 	correctionCounts   map[string]int // "scope"->N, "format"->N, "intent"->N
 	startTime          time.Time
+	projectKey         string
+	projectName        string // from the first record's cwd, else the slug
 }
 
+// agenticToolTurnShare is the share of a session's user-side turns that are
+// tool results above which it counts as agentic and its front-load ratio is
+// left out of the averages.
+const agenticToolTurnShare = 0.8
+
 // ---- Main computation ----
 
+// ClarityConfig tunes the clarity computation.
+type ClarityConfig struct {
+	ForceRegen bool // pick coaching tips afresh on every call instead of weekly
+
+	// MinSessionMessages is the fewest user prompts a session needs to count
+	// towards the averaged metrics (0 = default of 2). A one-prompt session
+	// has a front-load ratio of 1.0 by definition and would inflate the
+	// score; such sessions still count in SessionCount. Agentic sessions are
+	// averaged whatever their prompt count, since front-load is left out
+	// for them.
+	MinSessionMessages int
+}
+
 // ComputeClarity processes session JSONL files to produce a ClarityReport.
 // cutoff is the oldest allowed record timestamp; zero means no cutoff.
-func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
+func ComputeClarity(files []FileInfo, cutoff time.Time, cfg ClarityConfig) *ClarityReport {
 	stateMap := make(map[string]*sessionClarityState)
+	var assistantCount, toolCallCount int
 
 	for _, fi := range files {
 		if fi.Kind != KindSession {
@@ -254,7 +354,14 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 
 			state, ok := stateMap[sessionID]
 			if !ok {
-				state = &sessionClarityState{correctionCounts: make(map[string]int)}
+				state = &sessionClarityState{
+					correctionCounts: make(map[string]int),
+					projectKey:       fi.Root + fi.ProjectSlug, // as ProjectSummary.Key
+					projectName:      pathBase(slugToPath(fi.ProjectSlug)),
+				}
+				if rec.CWD != "" {
+					state.projectName = pathBase(rec.CWD)
+				}
 				stateMap[sessionID] = state
 			}
 
@@ -266,21 +373,37 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 			if isRealUserMessage(rec) {
 				text := extractText(rec.Message.Content)
 				if text != "" {
-					if len(state.userMessages) >= 1 {
-						if ctype, ok := detectCorrectionType(text); ok {
+					if len(state.userMessageLens) >= 1 {
+						if ctype, ok := detectCorrectionType(capText(text)); ok {
 							state.correctionCounts[ctype]++
 							state.correctionCount++
 						}
+					} else {
+						state.firstMessageWords = len(strings.Fields(text))
 					}
-					state.userMessages = append(state.userMessages, text)
+					state.userMessageLens = append(state.userMessageLens, len(text))
 				}
+			} else if _, ok := toolResultBytes(rec.Message.Content); ok && rec.Type == "user" {
+				state.toolTurnCount++
 			}
 
-			if rec.Type == "assistant" && state.firstAssistantText == "" {
+			if rec.Type == "assistant" {
+				assistantCount++
+				if hasToolUse(rec.Message.Content) {
+					toolCallCount++
+				}
+			}
+
+			if rec.Type == "assistant" {
 				text := extractText(rec.Message.Content)
 				if text != "" {
-					state.firstAssistantText = text
-					state.hadClarification = hasClarificationSignal(text)
+					state.responseChars += utf8.RuneCountInString(text)
+					state.responseCount++
+					if state.firstAssistantText == "" {
+						state.firstAssistantText = capText(text)
+						state.clarifyingPhrases = clarificationPhrases(state.firstAssistantText)
+						state.hadClarification = len(state.clarifyingPhrases) > 0
+					}
 				}
 			}
 		}
@@ -291,23 +414,47 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 		corrRate          float64
 		clarRate          float64
 		frontLoad         float64
+		agentic           bool // front-load left out, see agenticToolTurnShare
+		firstWords        float64
 		score             float64
 		startTime         time.Time
+		projectKey        string
+		projectName       string
 		correctionsByType map[string]float64
 	}
 
+	minMessages := cfg.MinSessionMessages
+	if minMessages <= 0 {
+		minMessages = 2
+	}
+
 	var allMetrics []sessionMetrics
+	sessionCount := 0
+	agenticCount := 0
+	phraseCounts := make(map[string]int)
+	var responseLenSum float64
+	responseSessions := 0
 
 	for _, state := range stateMap {
-		userMsgCount := len(state.userMessages)
+		userMsgCount := len(state.userMessageLens)
 		if userMsgCount == 0 {
-			continue // skip tool-only sessions
+			continue // skip tool-only sessions (every user record was a tool_result)
 		}
-
-		denom := userMsgCount - 1
-		if denom < 1 {
-			denom = 1
+		sessionCount++
+		if state.responseCount > 0 {
+			responseLenSum += float64(state.responseChars) / float64(state.responseCount)
+			responseSessions++
 		}
+		// Checked before the length filter: the typical agentic session is
+		// one prompt followed by tool results.
+		agentic := float64(state.toolTurnCount)/float64(userMsgCount+state.toolTurnCount) > agenticToolTurnShare
+		if userMsgCount < minMessages && !agentic {
+			continue // counted, but too short to average
+		}
+
+		// Corrections are only counted on follow-ups, so the denominator is
+		// the follow-up count — clamped to 1 so it can never be zero.
+		denom := max(userMsgCount-1, 1)
 		corrRate := float64(state.correctionCount) / float64(denom)
 		if corrRate > 1 {
 			corrRate = 1
@@ -315,19 +462,29 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 
 		var frontLoad float64
 		totalLen := 0
-		for _, m := range state.userMessages {
-			totalLen += len(m)
+		for _, n := range state.userMessageLens {
+			totalLen += n
 		}
 		if totalLen > 0 {
-			frontLoad = float64(len(state.userMessages[0])) / float64(totalLen)
+			frontLoad = float64(state.userMessageLens[0]) / float64(totalLen)
 		}
 
 		var clarRate float64
 		if state.hadClarification {
 			clarRate = 1.0
+			for _, ph := range state.clarifyingPhrases {
+				phraseCounts[ph]++
+			}
 		}
 
+		// In agentic sessions the prompts are a sliver of the turns and the
+		// first one is most of the prompt text by default, so front-loading
+		// says nothing; the score reweights the other two signals.
 		score := 100 * (0.40*frontLoad + 0.35*(1-corrRate) + 0.25*(1-clarRate))
+		if agentic {
+			agenticCount++
+			score = 100 * (0.35*(1-corrRate) + 0.25*(1-clarRate)) / 0.60
+		}
 
 		correctionsByType := make(map[string]float64)
 		for ctype, count := range state.correctionCounts {
@@ -338,35 +495,55 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 			corrRate:          corrRate,
 			clarRate:          clarRate,
 			frontLoad:         frontLoad,
+			agentic:           agentic,
+			firstWords:        float64(state.firstMessageWords),
 			score:             score,
 			startTime:         state.startTime,
+			projectKey:        state.projectKey,
+			projectName:       state.projectName,
 			correctionsByType: correctionsByType,
 		})
 	}
 
-	sessionCount := len(allMetrics)
-	if sessionCount < 2 {
-		return &ClarityReport{SessionCount: sessionCount}
+	var toolCallRate float64
+	if assistantCount > 0 {
+		toolCallRate = float64(toolCallCount) / float64(assistantCount)
+	}
+
+	var avgResponseLen float64
+	if responseSessions > 0 {
+		avgResponseLen = responseLenSum / float64(responseSessions)
 	}
 
-	// Overall: mean across sessions
-	var sumCorr, sumClar, sumFront, sumScore float64
-	n := float64(sessionCount)
+	scored := len(allMetrics)
+	if scored < 2 {
+		return &ClarityReport{SessionCount: sessionCount, ScoredSessionCount: scored, ToolCallRate: toolCallRate, AvgResponseLength: avgResponseLen}
+	}
+
+	// Overall: mean across scored sessions
+	var sumCorr, sumClar, sumFront, sumWords, sumScore float64
+	n := float64(scored)
+	frontN := 0
 	typeSums := map[string]float64{}
 	for _, m := range allMetrics {
 		sumCorr += m.corrRate
 		sumClar += m.clarRate
-		sumFront += m.frontLoad
+		if !m.agentic {
+			sumFront += m.frontLoad
+			frontN++
+		}
+		sumWords += m.firstWords
 		sumScore += m.score
 		for ctype, rate := range m.correctionsByType {
 			typeSums[ctype] += rate
 		}
 	}
 	overall := ClarityMetrics{
-		CorrectionRate:    sumCorr / n,
-		ClarificationRate: sumClar / n,
-		FrontLoadRatio:    sumFront / n,
-		Score:             sumScore / n,
+		CorrectionRate:       sumCorr / n,
+		ClarificationRate:    sumClar / n,
+		FrontLoadRatio:       sumFront / float64(max(frontN, 1)),
+		FirstMessageWordsAvg: sumWords / n,
+		Score:                sumScore / n,
 	}
 	overall.CorrectionsByType = make(map[string]float64)
 	for ctype, sum := range typeSums {
@@ -375,11 +552,12 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 
 	// Weekly grouping
 	type weekAccum struct {
-		corrSum   float64
-		clarSum   float64
-		frontSum  float64
-		scoreSum  float64
-		count     int
+		corrSum  float64
+		clarSum  float64
+		frontSum float64
+		frontN   int
+		scoreSum float64
+		count    int
 	}
 	weekMap := make(map[string]*weekAccum)
 
@@ -395,7 +573,10 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 		}
 		wa.corrSum += m.corrRate
 		wa.clarSum += m.clarRate
-		wa.frontSum += m.frontLoad
+		if !m.agentic {
+			wa.frontSum += m.frontLoad
+			wa.frontN++
+		}
 		wa.scoreSum += m.score
 		wa.count++
 	}
@@ -410,7 +591,7 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 			WeekStart:         weekKey,
 			CorrectionRate:    wa.corrSum / cnt,
 			ClarificationRate: wa.clarSum / cnt,
-			FrontLoadRatio:    wa.frontSum / cnt,
+			FrontLoadRatio:    wa.frontSum / float64(max(wa.frontN, 1)),
 			Score:             wa.scoreSum / cnt,
 			SessionCount:      wa.count,
 		})
@@ -419,6 +600,42 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 		return weekly[i].WeekStart < weekly[j].WeekStart
 	})
 
+	// Per-project ranking and front-loading
+	projectMap := make(map[string]*ProjectClarityRank)
+	frontSums := make(map[string]float64)
+	frontCounts := make(map[string]int)
+	for _, m := range allMetrics {
+		pr, ok := projectMap[m.projectKey]
+		if !ok {
+			pr = &ProjectClarityRank{ProjectKey: m.projectKey, ProjectName: m.projectName}
+			projectMap[m.projectKey] = pr
+		}
+		pr.Score += m.score // summed here, averaged below
+		pr.SessionCount++
+		if !m.agentic {
+			frontSums[m.projectKey] += m.frontLoad
+			frontCounts[m.projectKey]++
+		}
+	}
+	frontByProject := make(map[string]float64, len(frontSums))
+	for key, sum := range frontSums {
+		frontByProject[key] = sum / float64(frontCounts[key])
+	}
+	var byProject []ProjectClarityRank
+	for _, pr := range projectMap {
+		pr.Score /= float64(pr.SessionCount)
+		byProject = append(byProject, *pr)
+	}
+	sort.Slice(byProject, func(i, j int) bool {
+		if byProject[i].Score != byProject[j].Score {
+			return byProject[i].Score < byProject[j].Score
+		}
+		if byProject[i].ProjectName != byProject[j].ProjectName {
+			return byProject[i].ProjectName < byProject[j].ProjectName
+		}
+		return byProject[i].ProjectKey < byProject[j].ProjectKey
+	})
+
 	// Hourly grouping (local time)
 	type hourAccum struct {
 		scoreSum float64
@@ -465,14 +682,21 @@ func ComputeClarity(files []FileInfo, cutoff time.Time) *ClarityReport {
 	}
 
 	result := &ClarityReport{
-		Overall:       overall,
-		Weekly:        weekly,
-		SessionCount:  sessionCount,
-		HourlyBuckets: hourlyBuckets,
-		BestHour:      bestHour,
-		WorstHour:     worstHour,
-	}
-	result.Tips = SelectCoachingTips(result)
+		Overall:                      overall,
+		Weekly:                       weekly,
+		ScoreByProject:               byProject,
+		FrontLoadByProject:           frontByProject,
+		SessionCount:                 sessionCount,
+		ScoredSessionCount:           scored,
+		AgenticSessionCount:          agenticCount,
+		ToolCallRate:                 toolCallRate,
+		AvgResponseLength:            avgResponseLen,
+		HourlyBuckets:                hourlyBuckets,
+		ClarificationPhraseFrequency: topPhrases(phraseCounts, maxClarificationPhrases),
+		BestHour:                     bestHour,
+		WorstHour:                    worstHour,
+	}
+	result.Tips = SelectCoachingTips(result, cfg)
 	result.ScoreDelta = computeWeekDelta(result.Weekly)
 	return result
 }
@@ -518,6 +742,17 @@ func FrontLoadRatioInsight(r float64) MetricInsight {
 	}
 }
 
+// minFirstMessageWords is the average first-message length below which the
+// clarity section suggests adding context upfront.
+const minFirstMessageWords = 15
+
+func FirstMessageWordsInsight(avg float64) MetricInsight {
+	if avg < minFirstMessageWords {
+		return MetricInsight{"warn", fmt.Sprintf("Average first message is only %d words — try to include more context upfront.", int(math.Round(avg)))}
+	}
+	return MetricInsight{"good", "First messages carry enough words to set up the task."}
+}
+
 func ClarityScoreInsight(s float64) MetricInsight {
 	switch {
 	case s > 75:
@@ -548,5 +783,6 @@ var MetricDescriptions = map[string]string{
 	"correction_rate":     "% of your messages that walk back or contradict a prior request. Measures how precisely you specified intent the first time.",
 	"clarification_rate":  "% of sessions where the model asked a clarifying question in its first response. High = your prompts are underspecified.",
 	"front_load_ratio":    "% of your total prompt text that was in your first message. High = you front-loaded context; low = you trickled it in reactively.",
+	"first_message_words": "Mean word count of each session's first prompt. A high front-load ratio can still hide a very short opener.",
 	"clarity_score":       "Composite 0–100 from the three clarity signals. Tracks your prompting discipline over time.",
 }
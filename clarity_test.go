@@ -0,0 +1,176 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// A session whose user records are all tool results has no prompts to score.
+func TestComputeClarityToolResultsOnly(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	u := TokenUsage{InputTokens: 10, OutputTokens: 20}
+	fi := writeSession(t, dir, "-tmp-tools", testSession,
+		toolResult(t, testSession, ts(start), "file contents"),
+		assistantText(t, testSession, ts(start.Add(time.Minute)), "claude-sonnet-4", "Done.", u),
+		toolResult(t, testSession, ts(start.Add(2*time.Minute)), "more output"),
+	)
+
+	r := ComputeClarity([]FileInfo{fi}, time.Time{}, ClarityConfig{})
+	if r == nil {
+		t.Fatal("ComputeClarity returned nil")
+	}
+	if r.SessionCount != 0 || r.ScoredSessionCount != 0 {
+		t.Errorf("SessionCount, ScoredSessionCount = %d, %d; want 0, 0", r.SessionCount, r.ScoredSessionCount)
+	}
+	if r.Overall.FrontLoadRatio != 0 || r.Overall.Score != 0 {
+		t.Errorf("Overall = %+v, want zero", r.Overall)
+	}
+}
+
+// One prompt followed by tool results is the typical agentic session: it is
+// scored even though it has a single prompt, with front-load left out.
+func TestComputeClarityAgenticOnePrompt(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	u := TokenUsage{InputTokens: 10, OutputTokens: 20}
+	agentic := func(session string) FileInfo {
+		lines := []string{userText(t, session, ts(start), "Fix the failing tests in the parser package")}
+		for i := 1; i <= 5; i++ {
+			at := start.Add(time.Duration(2*i) * time.Minute)
+			lines = append(lines,
+				assistantText(t, session, ts(at.Add(-time.Minute)), "claude-sonnet-4-5", "Running the tests.", u),
+				toolResult(t, session, ts(at), "FAIL parser_test.go"),
+			)
+		}
+		return writeSession(t, dir, "-work-api", session, lines...)
+	}
+	chat := writeSession(t, dir, "-work-web", "11111111-2222-3333-4444-555555555555",
+		userText(t, "11111111-2222-3333-4444-555555555555", ts(start), "What does this regex match?"),
+		assistantText(t, "11111111-2222-3333-4444-555555555555", ts(start.Add(time.Minute)), "claude-sonnet-4-5", "Dates.", u),
+	)
+
+	r := ComputeClarity([]FileInfo{agentic(testSession), agentic(testOther), chat}, time.Time{}, ClarityConfig{})
+	if r.SessionCount != 3 || r.ScoredSessionCount != 2 || r.AgenticSessionCount != 2 {
+		t.Fatalf("SessionCount, ScoredSessionCount, AgenticSessionCount = %d, %d, %d; want 3, 2, 2",
+			r.SessionCount, r.ScoredSessionCount, r.AgenticSessionCount)
+	}
+	if r.Overall.FrontLoadRatio != 0 {
+		t.Errorf("FrontLoadRatio = %v, want 0 with only agentic sessions scored", r.Overall.FrontLoadRatio)
+	}
+	if r.Overall.Score != 100 {
+		t.Errorf("Score = %v, want 100 from correction and clarification rate alone", r.Overall.Score)
+	}
+}
+
+func TestCapText(t *testing.T) {
+	if s := strings.Repeat("a", maxTextLen); capText(s) != s {
+		t.Error("capText shortened a string of exactly maxTextLen bytes")
+	}
+	// "é" is two bytes, so an odd cap would split one without the rune
+	// boundary check.
+	s := strings.Repeat("é", maxTextLen)
+	got := capText(s)
+	if len(got) > maxTextLen || !utf8.ValidString(got) {
+		t.Errorf("capText: %d bytes, valid UTF-8 %v", len(got), utf8.ValidString(got))
+	}
+}
+
+// The text cap only guards signal matching: lengths and word counts come
+// from the whole message.
+func TestComputeClarityLongMessages(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	u := TokenUsage{InputTokens: 10, OutputTokens: 20}
+	first := strings.Repeat("word ", 3*maxTextLen/5) // 3×maxTextLen bytes
+	follow := strings.Repeat("more ", maxTextLen/5)  // maxTextLen bytes
+	reply := strings.Repeat("r", 2*maxTextLen)
+	var files []FileInfo
+	for _, id := range []string{testSession, testOther} {
+		files = append(files, writeSession(t, dir, "-work-api", id,
+			userText(t, id, ts(start), first),
+			assistantText(t, id, ts(start.Add(time.Minute)), "claude-sonnet-4-5", reply, u),
+			userText(t, id, ts(start.Add(2*time.Minute)), follow),
+			assistantText(t, id, ts(start.Add(3*time.Minute)), "claude-sonnet-4-5", reply, u),
+		))
+	}
+
+	r := ComputeClarity(files, time.Time{}, ClarityConfig{})
+	if r.ScoredSessionCount != 2 {
+		t.Fatalf("ScoredSessionCount = %d, want 2", r.ScoredSessionCount)
+	}
+	if got := r.Overall.FrontLoadRatio; math.Abs(got-0.75) > 1e-9 {
+		t.Errorf("FrontLoadRatio = %v, want 0.75", got)
+	}
+	if got, want := r.Overall.FirstMessageWordsAvg, float64(3*maxTextLen/5); got != want {
+		t.Errorf("FirstMessageWordsAvg = %v, want %v", got, want)
+	}
+	if got, want := r.AvgResponseLength, float64(len(reply)); got != want {
+		t.Errorf("AvgResponseLength = %v, want %v", got, want)
+	}
+}
+
+func TestComputeClarityResponseLengthRunes(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	u := TokenUsage{InputTokens: 10, OutputTokens: 20}
+	reply := strings.Repeat("数据", 1200) // 2,400 runes, 7,200 bytes
+	var files []FileInfo
+	for _, id := range []string{testSession, testOther} {
+		files = append(files, writeSession(t, dir, "-work-api", id,
+			userText(t, id, ts(start), "summarise the data model"),
+			assistantText(t, id, ts(start.Add(time.Minute)), "claude-sonnet-4-5", reply, u),
+			userText(t, id, ts(start.Add(2*time.Minute)), "in English please"),
+			assistantText(t, id, ts(start.Add(3*time.Minute)), "claude-sonnet-4-5", reply, u),
+		))
+	}
+
+	r := ComputeClarity(files, time.Time{}, ClarityConfig{})
+	if got := r.AvgResponseLength; got != 2400 {
+		t.Fatalf("AvgResponseLength = %v, want 2400", got)
+	}
+	var msg string
+	for _, in := range generateInsights(&AggregatedReport{Clarity: r}, nil) {
+		if strings.Contains(in.Message, "Model responses average") {
+			msg = in.Message
+		}
+	}
+	if !strings.Contains(msg, "average 2,400 chars") {
+		t.Errorf("verbose-reply insight = %q, want it to contain \"average 2,400 chars\"", msg)
+	}
+}
+
+// Per-project results are keyed by data directory and slug, so projects
+// that share a basename stay apart in both ScoreByProject and
+// FrontLoadByProject.
+func TestComputeClarityProjectsSameName(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	files := []FileInfo{
+		twoPromptSession(t, dir, "-work-a-api", testSession, start),
+		twoPromptSession(t, dir, "-work-b-api", testOther, start.Add(time.Hour)),
+	}
+	for i := range files {
+		files[i].Root = dir
+	}
+
+	r := ComputeClarity(files, time.Time{}, ClarityConfig{})
+	if len(r.ScoreByProject) != 2 || len(r.FrontLoadByProject) != 2 {
+		t.Fatalf("ScoreByProject = %+v, FrontLoadByProject = %v; want two projects each", r.ScoreByProject, r.FrontLoadByProject)
+	}
+	for i, slug := range []string{"-work-a-api", "-work-b-api"} {
+		key := (&ProjectSummary{DataDir: dir, Slug: slug}).Key()
+		if pr := r.ScoreByProject[i]; pr.ProjectKey != key || pr.ProjectName != "api" { // equal scores, so sorted by key
+			t.Errorf("ScoreByProject[%d] = %+v, want key %q named api", i, pr, key)
+		}
+		if _, ok := r.FrontLoadByProject[key]; !ok {
+			t.Errorf("FrontLoadByProject has no %q", key)
+		}
+		if name := r.ProjectName(key); name != "api" {
+			t.Errorf("ProjectName(%q) = %q, want api", key, name)
+		}
+	}
+}
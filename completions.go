@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// completionShells are the shells `completions` can generate scripts for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// runCompletions implements the `completions` subcommand:
+//
+//	token-analyzer completions bash|zsh|fish      print the script
+//	token-analyzer completions --install [SHELL]  hook it into the shell rc file
+//
+// The script is built from the flags registered on flag.CommandLine, so it
+// must run after they are defined. It returns the process exit code.
+func runCompletions(args []string) int {
+	fs := flag.NewFlagSet("completions", flag.ContinueOnError)
+	install := fs.Bool("install", false, "Add a line loading the completions to your shell rc file (shell from $SHELL unless given)")
+	dryRun := fs.Bool("dry-run", false, "With --install, print what would be added without writing")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s completions [--install [--dry-run]] [%s]\n", programName(), strings.Join(completionShells, "|"))
+		fs.PrintDefaults()
+	}
+	// Allow flags on either side of the shell name.
+	var shell string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if shell != "" {
+			fs.Usage()
+			return 2
+		}
+		shell, args = fs.Arg(0), fs.Args()[1:]
+	}
+	if shell == "" && *install {
+		shell = filepath.Base(os.Getenv("SHELL"))
+	}
+	if !isCompletionShell(shell) {
+		if shell == "" {
+			fmt.Fprintf(os.Stderr, "error: name a shell: %s\n", strings.Join(completionShells, ", "))
+		} else {
+			fmt.Fprintf(os.Stderr, "error: unsupported shell %q (want one of: %s)\n", shell, strings.Join(completionShells, ", "))
+		}
+		return 2
+	}
+	if *dryRun && !*install {
+		fmt.Fprintln(os.Stderr, "error: --dry-run only applies to --install")
+		return 2
+	}
+
+	if !*install {
+		if err := writeCompletionScript(os.Stdout, shell, programName()); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if err := installCompletions(os.Stdout, shell, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func isCompletionShell(shell string) bool {
+	for _, s := range completionShells {
+		if s == shell {
+			return true
+		}
+	}
+	return false
+}
+
+// programName is the command name the completions are registered for.
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// completionFlags lists the top-level flags, sorted by name.
+func completionFlags() []*flag.Flag {
+	var flags []*flag.Flag
+	flag.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// writeCompletionScript writes a completion script for shell that completes
+// the flag names of prog (plus the completions and help subcommands).
+func writeCompletionScript(w io.Writer, shell, prog string) error {
+	flags := completionFlags()
+	var err error
+	switch shell {
+	case "bash", "zsh":
+		words := []string{"completions", "help"}
+		for _, f := range flags {
+			words = append(words, "--"+f.Name)
+		}
+		fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(prog)
+		if shell == "zsh" {
+			_, err = fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+			if err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(w, "%s() {\n  COMPREPLY=($(compgen -W %q -- \"${COMP_WORDS[COMP_CWORD]}\"))\n}\ncomplete -o default -F %s %s\n",
+			fn, strings.Join(words, " "), fn, prog)
+	case "fish":
+		_, err = fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a completions -d 'Print or install shell completions'\n", prog)
+		if err == nil {
+			_, err = fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a help -d 'Explain a metric or report section'\n", prog)
+		}
+		for _, f := range flags {
+			if err != nil {
+				break
+			}
+			usage, _, _ := strings.Cut(f.Usage, "\n")
+			_, err = fmt.Fprintf(w, "complete -c %s -l %s -d '%s'\n", prog, f.Name, strings.ReplaceAll(usage, "'", `\'`))
+		}
+	}
+	return err
+}
+
+// completionHook returns the rc file for shell and the line that loads the
+// completions from it.
+func completionHook(shell, prog string) (rcPath, line string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	switch shell {
+	case "bash":
+		return filepath.Join(home, ".bashrc"), fmt.Sprintf(`eval "$(%s completions bash)"`, prog), nil
+	case "zsh":
+		return filepath.Join(home, ".zshrc"), fmt.Sprintf(`eval "$(%s completions zsh)"`, prog), nil
+	default:
+		return filepath.Join(home, ".config", "fish", "config.fish"), fmt.Sprintf("%s completions fish | source", prog), nil
+	}
+}
+
+// installCompletions appends the completion hook to the shell's rc file,
+// after copying the file to <rc>.bak. It does nothing if the line is already
+// there; with dryRun it only reports what it would add. A symlinked rc file,
+// as dotfile managers like stow and chezmoi create, is written through to
+// its target so the link survives.
+func installCompletions(w io.Writer, shell string, dryRun bool) error {
+	rcPath, line, err := completionHook(shell, programName())
+	if err != nil {
+		return err
+	}
+	target, err := filepath.EvalSymlinks(rcPath)
+	if errors.Is(err, os.ErrNotExist) {
+		target = rcPath
+	} else if err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(target)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, l := range strings.Split(string(existing), "\n") {
+		if strings.TrimSpace(l) == line {
+			fmt.Fprintf(w, "Completions already installed in %s\n", rcPath)
+			return nil
+		}
+	}
+	if dryRun {
+		fmt.Fprintf(w, "Would add to %s:\n  %s\n", rcPath, line)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := os.WriteFile(rcPath+".bak", existing, 0o644); err != nil {
+			return fmt.Errorf("backing up %s: %w", rcPath, err)
+		}
+	}
+	err = replaceFile(target, func(out io.Writer) error {
+		if _, err := out.Write(existing); err != nil {
+			return err
+		}
+		// Separate the hook from existing content by a blank line.
+		sep := ""
+		switch {
+		case len(existing) == 0:
+		case existing[len(existing)-1] != '\n':
+			sep = "\n\n"
+		default:
+			sep = "\n"
+		}
+		_, err := fmt.Fprintf(out, "%s# %s shell completions\n%s\n", sep, programName(), line)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Added to %s:\n  %s\n", rcPath, line)
+	if existing != nil {
+		fmt.Fprintf(w, "Backup saved as %s.bak. Open a new shell to use it.\n", rcPath)
+	} else {
+		fmt.Fprintln(w, "Open a new shell to use it.")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// A symlinked rc file, as stow or chezmoi leave in $HOME, stays a symlink
+// and the hook lands in the file it points to.
+func TestInstallCompletionsSymlinkedRC(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	managed := filepath.Join(home, "dotfiles", "bashrc")
+	if err := os.MkdirAll(filepath.Dir(managed), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(managed, []byte("alias ll='ls -l'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rc := filepath.Join(home, ".bashrc")
+	if err := os.Symlink(managed, rc); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := installCompletions(io.Discard, "bash", false); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Lstat(rc); err != nil || st.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("%s is no longer a symlink (%v)", rc, err)
+	}
+	got, err := os.ReadFile(managed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, line, _ := completionHook("bash", programName())
+	if !strings.HasPrefix(string(got), "alias ll='ls -l'\n") || !strings.Contains(string(got), line+"\n") {
+		t.Errorf("managed rc file = %q, want the original content followed by %q", got, line)
+	}
+
+	// A second install finds the hook through the link and adds nothing.
+	if err := installCompletions(io.Discard, "bash", false); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(managed); string(again) != string(got) {
+		t.Errorf("second install changed the file:\n%s", again)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// DiffRow compares one metric across the two projects of a ProjectDiff.
+type DiffRow struct {
+	Metric   string   `json:"metric"`
+	Unit     string   `json:"unit"` // "tokens", "usd", "count", "ratio" or "score"
+	A        float64  `json:"a"`
+	B        float64  `json:"b"`
+	Delta    float64  `json:"delta"`     // B - A
+	RelDelta *float64 `json:"rel_delta"` // (B - A) / A; nil when A is zero
+}
+
+// ProjectDiff is the --diff-projects comparison of project A against B.
+type ProjectDiff struct {
+	A    string    `json:"a"`
+	B    string    `json:"b"`
+	Rows []DiffRow `json:"rows"`
+}
+
+// DiffProjects compares every numeric field of two projects, plus a few
+// derived ratios, reporting B relative to A.
+func DiffProjects(a, b *ProjectSummary) *ProjectDiff {
+	d := &ProjectDiff{A: a.Name, B: b.Name}
+	ta, tb := a.Totals, b.Totals
+	perSession := func(p *ProjectSummary) float64 {
+		if p.SessionCount == 0 {
+			return 0
+		}
+		return p.Totals.CostUSD / float64(p.SessionCount)
+	}
+
+	d.add("Input tokens", "tokens", float64(ta.InputTokens), float64(tb.InputTokens))
+	d.add("Output tokens", "tokens", float64(ta.OutputTokens), float64(tb.OutputTokens))
+	d.add("Cache writes", "tokens", float64(ta.CacheCreationInputTokens), float64(tb.CacheCreationInputTokens))
+	d.add("Cache reads", "tokens", float64(ta.CacheReadInputTokens), float64(tb.CacheReadInputTokens))
+	d.add("Total tokens", "tokens", float64(ta.TotalTokens()), float64(tb.TotalTokens()))
+	d.add("Cache efficiency", "ratio", ta.CacheEfficiency(), tb.CacheEfficiency())
+	d.add("Cost", "usd", ta.CostUSD, tb.CostUSD)
+	d.add("Cache write cost", "usd", ta.CacheWriteCostUSD, tb.CacheWriteCostUSD)
+	d.add("Cache read cost", "usd", ta.CacheReadCostUSD, tb.CacheReadCostUSD)
+	d.add("API requests", "count", float64(ta.MessageCount), float64(tb.MessageCount))
+	d.add("Sessions", "count", float64(a.SessionCount), float64(b.SessionCount))
+	d.add("Sessions with subagents", "count", float64(a.SubagentCount), float64(b.SubagentCount))
+	d.add("Cost per session", "usd", perSession(a), perSession(b))
+	return d
+}
+
+// addClarity appends a clarity score row when both projects a and b, the
+// ones d compares, have one.
+func (d *ProjectDiff) addClarity(cl *ClarityReport, a, b *ProjectSummary) {
+	if cl == nil {
+		return
+	}
+	scores := make(map[string]float64, len(cl.ScoreByProject))
+	for _, pr := range cl.ScoreByProject {
+		scores[pr.ProjectKey] = pr.Score
+	}
+	sa, okA := scores[a.Key()]
+	sb, okB := scores[b.Key()]
+	if okA && okB {
+		d.add("Clarity score", "score", sa, sb)
+	}
+}
+
+func (d *ProjectDiff) add(metric, unit string, a, b float64) {
+	row := DiffRow{Metric: metric, Unit: unit, A: a, B: b, Delta: b - a}
+	if a != 0 {
+		rel := (b - a) / a
+		row.RelDelta = &rel
+	}
+	d.Rows = append(d.Rows, row)
+}
+
+// findProject resolves a --diff-projects argument: an exact (case-insensitive)
+// project name wins, otherwise the name must match exactly one project's name
+// or slug as a substring.
+func findProject(r *AggregatedReport, name string) (*ProjectSummary, error) {
+	var matches []*ProjectSummary
+	for _, p := range r.Projects {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+		if containsCI(p.Name, name) || containsCI(p.Slug, name) {
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no project matches %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, p := range matches {
+		names[i] = p.Name
+	}
+	return nil, fmt.Errorf("%q matches several projects: %s", name, strings.Join(names, ", "))
+}
+
+// PrintDiffProjects writes the --diff-projects comparison table.
+func PrintDiffProjects(w io.Writer, d *ProjectDiff, useColors bool) {
+	p := &Printer{w: w, useColors: useColors}
+	printDiffProjects(p, d)
+}
+
+func printDiffProjects(p *Printer, d *ProjectDiff) {
+	sectionHeader(p, "PROJECT COMPARISON")
+
+	p.println(p.dim(fmt.Sprintf("  %-24s  %s  %s  %14s  %8s",
+		"", padCellLeft(d.A, 14), padCellLeft(d.B, 14), "Δ (B − A)", "Δ %")))
+	for _, row := range d.Rows {
+		rel := p.gray(fmt.Sprintf("%8s", "—"))
+		if row.RelDelta != nil {
+			s := fmt.Sprintf("%+7.1f%%", *row.RelDelta*100)
+			switch {
+			case math.Abs(*row.RelDelta) < 0.005:
+				rel = s
+			case *row.RelDelta > 0:
+				rel = p.yellow(s)
+			default:
+				rel = p.cyan(s)
+			}
+		}
+		p.printf("  %-24s  %14s  %14s  %14s  %s\n", row.Metric,
+			fmtDiffValue(row.Unit, row.A, false), fmtDiffValue(row.Unit, row.B, false),
+			fmtDiffValue(row.Unit, row.Delta, true), rel)
+	}
+	p.println("")
+}
+
+// fmtDiffValue formats a DiffRow value for its unit; signed adds a leading
+// + for non-negative deltas.
+func fmtDiffValue(unit string, v float64, signed bool) string {
+	sign := ""
+	if signed && v >= 0 {
+		sign = "+"
+	}
+	switch unit {
+	case "tokens", "count":
+		n := int64(math.Round(v))
+		if n < 0 {
+			return "-" + fmtTokens(-n)
+		}
+		return sign + fmtTokens(n)
+	case "usd":
+		if v < 0 {
+			return "-" + fmtCost(-v)
+		}
+		return sign + fmtCost(v)
+	case "ratio":
+		return sign + fmtPct(v)
+	default:
+		return sign + fmt.Sprintf("%.0f", v)
+	}
+}
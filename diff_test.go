@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Project names in the header must end where the values under them end,
+// whatever their display width.
+func TestPrintDiffProjectsHeaderWidth(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"api", "web"},
+		{"数据平台", "🚀-launch"},
+		{"a-very-long-project-name", "数据平台数据平台数据平台"},
+	}
+	for _, tt := range tests {
+		r := fixtureReport()
+		a, b := *r.Projects[0], *r.Projects[1]
+		a.Name, b.Name = tt.a, tt.b
+		var buf bytes.Buffer
+		PrintDiffProjects(&buf, DiffProjects(&a, &b), false)
+		lines := strings.Split(buf.String(), "\n")
+
+		var header, row string
+		for i, line := range lines {
+			if strings.Contains(line, "Δ (B − A)") {
+				header, row = line, lines[i+1]
+				break
+			}
+		}
+		if header == "" {
+			t.Fatalf("%s/%s: no header in\n%s", tt.a, tt.b, buf.String())
+		}
+		// Columns: 2 + 24 label cells, then two 14-cell value columns.
+		endA, endB := 2+24+2+14, 2+24+2+14+2+14
+		nameA, nameB := truncate(tt.a, 14), truncate(tt.b, 14)
+		if got := columnEnd(header, nameA); got != endA {
+			t.Errorf("%s ends at cell %d, want %d:\n%s", nameA, got, endA, header)
+		}
+		if got := columnEnd(header, nameB); got != endB {
+			t.Errorf("%s ends at cell %d, want %d:\n%s", nameB, got, endB, header)
+		}
+		if got := columnEnd(row, "1,400"); got != endA {
+			t.Errorf("value under %s ends at cell %d, want %d:\n%s", nameA, got, endA, row)
+		}
+	}
+}
+
+func TestPadCellLeft(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"api", 6, "   api"},
+		{"数据", 6, "  数据"},
+		{"数据平台", 6, " 数据…"},
+		{"", 2, "  "},
+	}
+	for _, tt := range tests {
+		if got := padCellLeft(tt.s, tt.n); got != tt.want {
+			t.Errorf("padCellLeft(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+// Two projects with the same basename each get their own clarity score.
+func TestDiffProjectsClaritySameName(t *testing.T) {
+	a := &ProjectSummary{Slug: "-work-a-api", Name: "api"}
+	b := &ProjectSummary{Slug: "-work-b-api", Name: "api"}
+	cl := &ClarityReport{ScoreByProject: []ProjectClarityRank{
+		{ProjectKey: a.Key(), ProjectName: "api", Score: 40, SessionCount: 2},
+		{ProjectKey: b.Key(), ProjectName: "api", Score: 80, SessionCount: 2},
+	}}
+	d := DiffProjects(a, b)
+	d.addClarity(cl, a, b)
+	row := d.Rows[len(d.Rows)-1]
+	if row.Metric != "Clarity score" || row.A != 40 || row.B != 80 {
+		t.Errorf("last row = %+v, want Clarity score 40 → 80", row)
+	}
+}
@@ -2,27 +2,40 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 var (
 	uuidRegex    = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
 	agentIDRegex = regexp.MustCompile(`^agent-[0-9a-f]+\.jsonl$`)
+	// Windows slugs encode "C:\Users\foo" as "C--Users-foo".
+	windowsSlugRegex = regexp.MustCompile(`^([A-Za-z])--(.*)$`)
 )
 
 // DiscoverFiles walks the ~/.claude/projects/ directory and returns
-// all classified JSONL session and subagent files.
-func DiscoverFiles(claudeDir string) ([]FileInfo, error) {
+// all classified JSONL session and subagent files, plus the paths that
+// were skipped because they could not be read (permission denied).
+func DiscoverFiles(claudeDir string) ([]FileInfo, []string, error) {
 	projectsDir := filepath.Join(claudeDir, "projects")
 
 	var files []FileInfo
+	var warnings []string
 
 	err := filepath.WalkDir(projectsDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
-			return nil // skip unreadable entries
+			// Skip unreadable entries, but remember permission failures so
+			// the report can say data is missing.
+			if errors.Is(err, fs.ErrPermission) {
+				warnings = append(warnings, path)
+			}
+			return nil
 		}
 		if d.IsDir() {
 			return nil
@@ -52,14 +65,20 @@ func DiscoverFiles(claudeDir string) ([]FileInfo, error) {
 				})
 			}
 
-		case len(parts) == 4 && parts[2] == "subagents" && agentIDRegex.MatchString(parts[3]):
-			// <slug>/<uuid>/subagents/agent-<id>.jsonl
-			agentID := strings.TrimSuffix(parts[3], ".jsonl")
+		case len(parts) >= 3 && agentIDRegex.MatchString(parts[len(parts)-1]):
+			// <slug>/<uuid>/subagents/agent-<id>.jsonl, or newer layouts such as
+			// <slug>/<uuid>/agents/agent-<id>.jsonl with any extra nesting.
+			// The session is the nearest UUID-named ancestor directory.
+			sessionID := nearestUUID(parts[1 : len(parts)-1])
+			if sessionID == "" {
+				return nil
+			}
+			agentID := strings.TrimSuffix(parts[len(parts)-1], ".jsonl")
 			files = append(files, FileInfo{
 				Path:        path,
 				Kind:        KindSubagent,
 				ProjectSlug: parts[0],
-				SessionID:   parts[1],
+				SessionID:   sessionID,
 				AgentID:     agentID,
 			})
 		}
@@ -68,10 +87,100 @@ func DiscoverFiles(claudeDir string) ([]FileInfo, error) {
 	})
 
 	if err != nil && !os.IsNotExist(err) {
-		return nil, err
+		return nil, nil, err
+	}
+
+	return files, warnings, nil
+}
+
+// nearestUUID returns the last element of dirs that looks like a session
+// UUID, or "" if none does.
+func nearestUUID(dirs []string) string {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if uuidRegex.MatchString(dirs[i]) {
+			return dirs[i]
+		}
 	}
+	return ""
+}
 
-	return files, nil
+// ModifiedSince returns the files whose modification time is at or after
+// since. Session logs are append-only, so an older file holds no records
+// newer than since. Files that can't be stat'ed are kept.
+func ModifiedSince(files []FileInfo, since time.Time) []FileInfo {
+	var out []FileInfo
+	for _, fi := range files {
+		st, err := os.Stat(fi.Path)
+		if err == nil && st.ModTime().Before(since) {
+			continue
+		}
+		out = append(out, fi)
+	}
+	return out
+}
+
+// HealthCheck reports whether claudeDir holds session data whose newest file
+// was written within maxAgeHours (0 skips the age check). It backs
+// --health-check; the error says what is wrong.
+func HealthCheck(claudeDir string, maxAgeHours int) error {
+	projectsDir := filepath.Join(claudeDir, "projects")
+	if st, err := os.Stat(projectsDir); err != nil || !st.IsDir() {
+		return fmt.Errorf("%s: no projects directory", claudeDir)
+	}
+	files, _, err := DiscoverFiles(claudeDir)
+	if err != nil {
+		return fmt.Errorf("%s: %v", claudeDir, err)
+	}
+	var newest time.Time
+	var newestPath string
+	for _, fi := range files {
+		if st, err := os.Stat(fi.Path); err == nil && st.ModTime().After(newest) {
+			newest, newestPath = st.ModTime(), fi.Path
+		}
+	}
+	if newest.IsZero() {
+		return fmt.Errorf("%s: no session files", claudeDir)
+	}
+	if maxAgeHours > 0 {
+		if age := time.Since(newest); age > time.Duration(maxAgeHours)*time.Hour {
+			return fmt.Errorf("%s: newest data is %s old (limit %dh): %s", claudeDir, fmtDuration(age), maxAgeHours, newestPath)
+		}
+	}
+	return nil
+}
+
+// DiscoverAll runs DiscoverFiles over each data directory and merges the
+// results. Unless mergeProjects is set, files from different directories are
+// tagged with their Root so identical project slugs stay separate. Skipped
+// paths from every directory are returned together.
+func DiscoverAll(claudeDirs []string, mergeProjects bool) ([]FileInfo, []string, error) {
+	var all []FileInfo
+	var warnings []string
+	for _, dir := range claudeDirs {
+		files, skipped, err := DiscoverFiles(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, skipped...)
+		if len(claudeDirs) > 1 && !mergeProjects {
+			for i := range files {
+				files[i].Root = dir
+			}
+		}
+		all = append(all, files...)
+	}
+	return all, warnings, nil
+}
+
+// ParseStatsCacheAll returns the first stats-cache.json found among the data
+// directories, or nil if none has one.
+func ParseStatsCacheAll(claudeDirs []string) *StatsCache {
+	for _, dir := range claudeDirs {
+		if sc := ParseStatsCache(dir); sc != nil {
+			return sc
+		}
+	}
+	return nil
 }
 
 // ParseStatsCache reads ~/.claude/stats-cache.json.
@@ -90,10 +199,51 @@ func ParseStatsCache(claudeDir string) *StatsCache {
 }
 
 // slugToPath converts a project slug like "-Users-foo-bar" to "/Users/foo/bar".
+// Windows slugs like "C--Users-foo-bar" become "C:\Users\foo\bar".
 // This is a best-effort fallback — use cwd from parsed records when available.
 func slugToPath(slug string) string {
 	if slug == "" {
 		return ""
 	}
-	return "/" + strings.TrimPrefix(slug, "-")
+	if m := windowsSlugRegex.FindStringSubmatch(slug); m != nil {
+		drive := strings.ToUpper(m[1]) + `:\`
+		if m[2] == "" {
+			return drive
+		}
+		return joinSlugParts(drive, `\`, strings.Split(m[2], "-"))
+	}
+	return joinSlugParts("/", "/", strings.Split(strings.TrimPrefix(slug, "-"), "-"))
+}
+
+// joinSlugParts rebuilds a path from hyphen-split slug parts. A hyphen is
+// treated as a separator unless only the hyphenated name exists on disk, so
+// directories like "my-app" survive when the path is still present.
+func joinSlugParts(root, sep string, parts []string) string {
+	path := root + parts[0]
+	for _, part := range parts[1:] {
+		if !pathExists(path+sep+part) && pathExists(path+"-"+part) {
+			path += "-" + part
+		} else {
+			path += sep + part
+		}
+	}
+	return path
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// pathBase returns the last element of a POSIX or Windows path regardless of
+// the host OS, so Windows cwds still yield sensible project names elsewhere.
+func pathBase(path string) string {
+	path = strings.TrimRight(path, `/\`)
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		path = path[i+1:]
+	}
+	if path == "" {
+		return filepath.Base(path)
+	}
+	return path
 }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+const (
+	testSession = "0a1b2c3d-4e5f-6789-abcd-ef0123456789"
+	testOther   = "fedcba98-7654-3210-fedc-ba9876543210"
+)
+
+func TestDiscoverFilesRegex(t *testing.T) {
+	uuids := map[string]bool{
+		testSession:                                  true,
+		"00000000-0000-0000-0000-000000000000":       true,
+		"0A1B2C3D-4E5F-6789-ABCD-EF0123456789":       false, // Claude Code writes lowercase
+		"0a1b2c3d-4e5f-6789-abcd-ef012345678":        false,
+		"0a1b2c3d4e5f6789abcdef0123456789":           false,
+		"0a1b2c3d-4e5f-6789-abcd-ef0123456789.jsonl": false,
+		"": false,
+	}
+	for s, want := range uuids {
+		if got := uuidRegex.MatchString(s); got != want {
+			t.Errorf("uuidRegex.MatchString(%q) = %v, want %v", s, got, want)
+		}
+	}
+	agents := map[string]bool{
+		"agent-a1b2c3.jsonl": true,
+		"agent-0.jsonl":      true,
+		"agent-.jsonl":       false,
+		"agent-xyz.jsonl":    false,
+		"agent-a1b2c3.json":  false,
+		"agent-a1b2c3":       false,
+		"Agent-a1b2c3.jsonl": false,
+		"x-agent-a1.jsonl":   false,
+	}
+	for s, want := range agents {
+		if got := agentIDRegex.MatchString(s); got != want {
+			t.Errorf("agentIDRegex.MatchString(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestDiscoverFilesLayout(t *testing.T) {
+	dir := t.TempDir()
+	layout := []string{
+		// Classified.
+		"proj-a/" + testSession + ".jsonl",
+		"proj-a/" + testSession + "/subagents/agent-a1.jsonl",
+		"proj-a/" + testSession + "/agents/agent-b2.jsonl",
+		"proj-b/" + testOther + "/subagents/nested/agent-c3.jsonl",
+		// Ignored.
+		testSession + ".jsonl",                         // no project directory
+		"proj-a/notes.jsonl",                           // non-UUID session name
+		"proj-a/" + testSession + ".json",              // wrong extension
+		"proj-a/nested/" + testSession + ".jsonl",      // session file too deep
+		"proj-a/agent-d4.jsonl",                        // agent file at session depth
+		"proj-a/misc/subagents/agent-e5.jsonl",         // no session directory
+		"proj-a/" + testSession + "/subagents/x.jsonl", // non-agent name
+	}
+	for _, rel := range layout {
+		path := filepath.Join(dir, "projects", filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, warnings, err := DiscoverFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v, want none", warnings)
+	}
+	kinds := map[FileKind]string{KindSession: "session", KindSubagent: "subagent"}
+	var got []string
+	for _, fi := range files {
+		rel, _ := filepath.Rel(filepath.Join(dir, "projects"), fi.Path)
+		got = append(got, filepath.ToSlash(rel)+" "+kinds[fi.Kind]+" "+fi.ProjectSlug+" "+fi.SessionID+" "+fi.AgentID)
+	}
+	sort.Strings(got)
+	want := []string{
+		"proj-a/" + testSession + "/agents/agent-b2.jsonl subagent proj-a " + testSession + " agent-b2",
+		"proj-a/" + testSession + "/subagents/agent-a1.jsonl subagent proj-a " + testSession + " agent-a1",
+		"proj-a/" + testSession + ".jsonl session proj-a " + testSession + " ",
+		"proj-b/" + testOther + "/subagents/nested/agent-c3.jsonl subagent proj-b " + testOther + " agent-c3",
+	}
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("DiscoverFiles found %d files:\n%v\nwant %d:\n%v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDiscoverFilesMissingDir(t *testing.T) {
+	files, _, err := DiscoverFiles(filepath.Join(t.TempDir(), "absent"))
+	if err != nil || len(files) != 0 {
+		t.Errorf("DiscoverFiles(missing) = %v, %v; want no files and no error", files, err)
+	}
+}
+
+func TestSlugToPath(t *testing.T) {
+	tests := []struct {
+		slug string
+		want string
+	}{
+		{"", ""},
+		{"-Users-foo-bar", "/Users/foo/bar"},
+		{"-home-dev-api", "/home/dev/api"},
+		{"C--Users-foo-bar", `C:\Users\foo\bar`},
+		{"d--work-api", `D:\work\api`},
+		{"C--", `C:\`},
+	}
+	for _, tt := range tests {
+		if got := slugToPath(tt.slug); got != tt.want {
+			t.Errorf("slugToPath(%q) = %q, want %q", tt.slug, got, tt.want)
+		}
+	}
+}
+
+// A hyphen stays part of the name when only the hyphenated directory exists.
+func TestJoinSlugPartsHyphenatedDirs(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src", "my-app", "web"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sep := string(filepath.Separator)
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"src", "my", "app", "web"}, filepath.Join(root, "src", "my-app", "web")},
+		{[]string{"src", "gone", "dir"}, filepath.Join(root, "src", "gone", "dir")},
+	}
+	for _, tt := range tests {
+		if got := joinSlugParts(root+sep, sep, tt.parts); got != tt.want {
+			t.Errorf("joinSlugParts(%v) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestPathBase(t *testing.T) {
+	tests := map[string]string{
+		"/Users/foo/bar":    "bar",
+		"/Users/foo/bar/":   "bar",
+		`C:\Users\foo\bar`:  "bar",
+		`C:\Users\foo\bar\`: "bar",
+		"bar":               "bar",
+	}
+	for path, want := range tests {
+		if got := pathBase(path); got != want {
+			t.Errorf("pathBase(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRecord builds one JSONL line in the shape Claude Code writes.
+func testRecord(t *testing.T, typ, session, ts string, content any, usage *TokenUsage, model string) string {
+	t.Helper()
+	msg := map[string]any{"role": typ, "content": content}
+	if usage != nil {
+		msg["usage"] = usage
+		msg["model"] = model
+	}
+	rec := map[string]any{
+		"uuid":      newTestUUID(),
+		"type":      typ,
+		"sessionId": session,
+		"timestamp": ts,
+		"message":   msg,
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+var testUUIDSeq int
+
+// newTestUUID returns a distinct UUID-shaped string on every call.
+func newTestUUID() string {
+	testUUIDSeq++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", testUUIDSeq)
+}
+
+func userText(t *testing.T, session, ts, text string) string {
+	return testRecord(t, "user", session, ts, text, nil, "")
+}
+
+func toolResult(t *testing.T, session, ts, output string) string {
+	return testRecord(t, "user", session, ts, []map[string]any{
+		{"type": "tool_result", "tool_use_id": "x", "content": output},
+	}, nil, "")
+}
+
+func assistantText(t *testing.T, session, ts, model, text string, u TokenUsage) string {
+	return testRecord(t, "assistant", session, ts, []map[string]any{
+		{"type": "text", "text": text},
+	}, &u, model)
+}
+
+// writeSession writes lines as <dir>/projects/<slug>/<session>.jsonl and
+// returns its FileInfo.
+func writeSession(t *testing.T, dir, slug, session string, lines ...string) FileInfo {
+	t.Helper()
+	path := filepath.Join(dir, "projects", slug, session+".jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return FileInfo{Path: path, Kind: KindSession, ProjectSlug: slug, SessionID: session}
+}
+
+// ts formats a UTC time the way session logs do.
+func ts(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
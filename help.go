@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// HelpText is the extended explanation `token-analyzer help <topic>` prints
+// for each metric (after its MetricDescriptions line) and for each section.
+// Paragraphs are separated by blank lines and wrapped when printed.
+var HelpText = map[string]string{
+	// ---- Metrics ----
+	"total_tokens": `Every token the API billed for, whatever its type: uncached input, output, cache writes and cache reads. Cache reads usually dominate in Claude Code, because each turn re-sends the whole conversation and most of it is served from the prompt cache.
+
+A large total is therefore not the same as a large bill. Compare it with estimated_cost and cache_efficiency before drawing conclusions: ten million cache-read tokens cost less than one million uncached input tokens.`,
+
+	"cache_efficiency": `The share of input-side tokens that were served from the prompt cache: cache reads divided by (input + cache writes + cache reads). Output tokens are left out because they can never be cached.
+
+Cache reads are billed at about a tenth of the input rate, so this is the single biggest lever on cost. Above 75% is excellent and typical for long sessions; 40–75% is moderate; below 40% usually means many short sessions, frequent context resets, or a CLAUDE.md and system prompt that keep changing and so invalidate the cache.
+
+To raise it, keep working in one session rather than starting fresh for every small task, and avoid editing files that are loaded into every prompt in the middle of a session.`,
+
+	"estimated_cost": `The sum over every assistant message of its tokens times the per-million-token rate for its model family, with separate rates for input, output, cache writes and cache reads. Run "token-analyzer --model-pricing-table" to see the rates used.
+
+It is an estimate: it reflects list prices, not discounts, subscription plans or billing rounding, and models the built-in table does not know are counted at $0. For exact figures use the Anthropic console; use this to compare projects, sessions and periods against each other.`,
+
+	"sessions": `A session is one Claude Code conversation: a <uuid>.jsonl file under ~/.claude/projects/<project>/, plus the subagent files stored next to it. Resuming a conversation continues the same session.
+
+Session counts cover sessions with at least one assistant message in the report window. The session-size percentiles (P50/P90/P99) show how tokens are spread across them: a P99 far above P50 means a few very long sessions drive most of the spend.`,
+
+	"input_tokens": `Prompt tokens that were not served from the cache and not written to it: the part of each request the model had to read fresh at the full input rate.
+
+In Claude Code this is usually a small share of the total, because the conversation so far is cached. A high share of input tokens suggests sessions that keep restarting or context that changes on every turn.`,
+
+	"output_tokens": `Tokens the model generated, including tool calls and, for models that support it, extended thinking. Output is billed at about five times the input rate, so it often accounts for a large part of the cost even though it is a small part of the token count.
+
+Long output usually comes from the model writing whole files or long explanations. Asking for diffs, or for a concise answer, is the most direct way to reduce it.`,
+
+	"correction_rate": `The share of your follow-up prompts that walk back or redirect an earlier request, such as "actually, …", "scratch that" or "no, only change…". The first prompt of a session can never be a correction, so the rate is corrections divided by follow-ups.
+
+Corrections are classified as scope (you narrowed or widened what should change), format (you asked for a different output shape) or intent (you restated the goal). Each type has a different fix: a constraints block, naming the output format up front, or leading with the goal.
+
+Detection is a phrase heuristic on the start of each message, so treat it as a trend indicator rather than an exact count. Below 10% is good; above 25% means the first prompt regularly leaves out something you later have to add.`,
+
+	"clarification_rate": `The share of sessions in which the model's first reply asks you a clarifying question ("could you clarify", "what exactly", "do you want…"). It only looks at the first reply, because that is the one that reacts to your opening prompt alone.
+
+A high rate means opening prompts leave out information the model cannot infer: which files, which behaviour, what "done" looks like. "help clarity" lists how this feeds the clarity score, and the terminal report lists the phrases that triggered it most often.`,
+
+	"front_load_ratio": `The length of your first prompt divided by the total length of all your prompts in the session. A ratio near 1 means you gave the context up front; a low ratio means it trickled in over many follow-ups.
+
+Front-loading matters because context given late cannot shape the first attempt, and every extra turn re-sends the whole conversation. One-prompt sessions (ratio 1 by definition) and agentic sessions that are mostly tool results are left out of the average.
+
+Above 60% is good; below 40% is worth working on: paste the relevant code, constraints and acceptance criteria into the opening message.`,
+
+	"first_message_words": `The average word count of each session's opening prompt. It complements front_load_ratio: a session with one short opener and one short follow-up still has a ratio of 50%, yet neither message gave the model much to work with.
+
+Under 15 words triggers a nudge to add context. Short openers are fine for quick questions; for changes to code, name the files, the behaviour you want and what must not change.`,
+
+	"clarity_score": `A 0–100 composite of the three clarity signals, weighted 40% front-load ratio, 35% (1 − correction rate) and 25% (1 − clarification rate). Agentic sessions are scored on the last two alone.
+
+Only sessions with at least two real prompts, or agentic ones that are mostly tool results, are scored, and the section needs two such sessions. Above 75 is strong, 50–75 is average, and below 50 means most detail arrives through follow-ups. The weekly trend and the per-project and time-of-day views use the same score.`,
+
+	// ---- Sections ----
+	"clarity": `The prompt clarity section scores how well-specified your prompts are, from the session logs alone: correction_rate, clarification_rate and front_load_ratio feed a 0–100 clarity_score, with first_message_words shown alongside. Run "token-analyzer help <metric>" for each.
+
+The metrics are heuristics over message text, averaged across sessions with at least two real prompts (tool results do not count as prompts) and agentic sessions, which are mostly tool results. They are meant to show trends, such as a weekly score going up or one project scoring far below the others, rather than to grade a single session.
+
+The section also lists the best and worst hours of the day, the correction types you make most, and a coaching tip for the weakest metric, rotated weekly. Skip the whole analysis with --no-clarity.`,
+
+	"pricing": `Costs come from a built-in table of per-million-token rates per model family (input, output, cache write, cache read), matched on the longest prefix of the model ID, so "claude-sonnet-4-5-20250929" uses the claude-sonnet-4 rates. Print the table with --model-pricing-table.
+
+Cache writes cost about 1.25× the input rate and cache reads about 0.1×; the report shows what cached tokens would have cost as plain input, and the savings. Thinking tokens are billed as output.
+
+Models missing from the table are counted at $0, so a new model can make costs look low until the table is updated. All figures are list-price estimates.`,
+
+	"cache": `Claude Code caches the conversation prefix, so each turn only pays full price for what changed. The report shows cache writes (tokens stored, slightly dearer than input), cache reads (tokens reused, about a tenth of the input price) and cache_efficiency, the share of input-side tokens that were reads.
+
+See "help cache_efficiency" for what good looks like and how to improve it, and "help pricing" for the rates.`,
+}
+
+// helpAliases maps other spellings of a topic to its HelpText key.
+var helpAliases = map[string]string{
+	"cost":    "estimated_cost",
+	"caching": "cache",
+	"score":   "clarity_score",
+}
+
+// runHelp implements `token-analyzer help [topic]`. With no topic it lists
+// the topics; it returns the process exit code.
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printHelpTopics(os.Stdout)
+		return 0
+	}
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s help [topic]\n", programName())
+		return 2
+	}
+	topic := helpTopic(args[0])
+	text, ok := HelpText[topic]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: no help for %q\n\n", args[0])
+		printHelpTopics(os.Stderr)
+		return 2
+	}
+	printHelp(os.Stdout, topic, text)
+	return 0
+}
+
+// helpTopic normalizes a topic name: case, hyphens, and aliases.
+func helpTopic(name string) string {
+	t := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+	if alias, ok := helpAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// helpWidth is the column help text is wrapped at.
+const helpWidth = 78
+
+func printHelp(w io.Writer, topic, text string) {
+	fmt.Fprintln(w, strings.ToUpper(strings.ReplaceAll(topic, "_", " ")))
+	fmt.Fprintln(w)
+	if desc, ok := MetricDescriptions[topic]; ok {
+		fmt.Fprintln(w, WordWrapCJK(desc, helpWidth))
+		fmt.Fprintln(w)
+	}
+	for i, para := range strings.Split(text, "\n\n") {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, WordWrapCJK(para, helpWidth))
+	}
+}
+
+func printHelpTopics(w io.Writer) {
+	var metrics, sections []string
+	for topic := range HelpText {
+		if _, ok := MetricDescriptions[topic]; ok {
+			metrics = append(metrics, topic)
+		} else {
+			sections = append(sections, topic)
+		}
+	}
+	sort.Strings(metrics)
+	sort.Strings(sections)
+	fmt.Fprintf(w, "usage: %s help <topic>\n\n", programName())
+	fmt.Fprintf(w, "Metrics:  %s\n", strings.Join(metrics, ", "))
+	fmt.Fprintf(w, "Sections: %s\n", strings.Join(sections, ", "))
+	fmt.Fprintf(w, "\nFlags are listed by %s --help.\n", programName())
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixtureMeta is a ReportMeta with fixed values, for golden output.
+func fixtureMeta(legacy bool) *ReportMeta {
+	m := newReportMeta([]string{"/home/dev/.claude"}, 4, AggregateOptions{}, "info", legacy)
+	m.GeneratedAt = "2026-10-05T12:00:00Z"
+	m.ToolVersion = "v1.0.0"
+	return m
+}
+
+// The JSON report is a public schema: any change to its keys shows up as a
+// golden diff and needs a schemaVersion bump unless it only adds fields.
+func TestJSONReportGolden(t *testing.T) {
+	styles := []struct {
+		golden string
+		style  jsonStyle
+	}{
+		{"report.json", jsonStyle{}},
+		{"report_full.json", jsonStyle{Full: true}},
+		{"report_legacy.json", jsonStyle{Legacy: true}},
+	}
+	for _, s := range styles {
+		t.Run(s.golden, func(t *testing.T) {
+			r := fixtureReport()
+			r.Meta = fixtureMeta(s.style.Legacy)
+			var buf bytes.Buffer
+			if err := writeJSON(&buf, r, true, s.style); err != nil {
+				t.Fatal(err)
+			}
+			if !json.Valid(buf.Bytes()) {
+				t.Fatal("output is not valid JSON")
+			}
+			checkGolden(t, s.golden, buf.Bytes())
+		})
+	}
+}
+
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// Every exported field reachable from the report types needs an explicit
+// snake_case json tag, or "-" to leave it out.
+func TestJSONTags(t *testing.T) {
+	seen := make(map[reflect.Type]bool)
+	pkg := reflect.TypeOf(UsageTotals{}).PkgPath()
+	var walk func(t reflect.Type, path string)
+	walk = func(typ reflect.Type, path string) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || seen[typ] || typ.PkgPath() != pkg {
+			return
+		}
+		seen[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if !f.IsExported() && !f.Anonymous {
+				continue
+			}
+			tag := f.Tag.Get("json")
+			key, _, _ := strings.Cut(tag, ",")
+			if key == "-" {
+				continue
+			}
+			walk(f.Type, path+"."+f.Name)
+			if f.Anonymous && tag == "" {
+				continue // promoted fields are checked on the embedded type
+			}
+			if !snakeCase.MatchString(key) {
+				t.Errorf("%s.%s: json tag %q is not snake_case", path, f.Name, tag)
+			}
+		}
+	}
+	for _, v := range []any{AggregatedReport{}, ndjsonSession{}, UsageRecord{}, SessionDetail{}, ProjectDetail{}, SessionPage{}, Health{}} {
+		typ := reflect.TypeOf(v)
+		walk(typ, typ.Name())
+	}
+}
+
+// releasedSchemaVersion is the newest schemaVersion that has shipped. It only
+// ever moves up, together with schemaVersion.
+const releasedSchemaVersion = 3
+
+func TestSchemaVersion(t *testing.T) {
+	if schemaVersion < releasedSchemaVersion {
+		t.Errorf("schemaVersion = %d, went below released version %d", schemaVersion, releasedSchemaVersion)
+	}
+	if v := fixtureMeta(true).SchemaVersion; v != 1 {
+		t.Errorf("legacy schema_version = %d, want 1", v)
+	}
+	if v := fixtureMeta(false).SchemaVersion; v != schemaVersion {
+		t.Errorf("schema_version = %d, want %d", v, schemaVersion)
+	}
+}
+
+// Every JSON style carries the meta block, with schema_version first so a
+// reader can check it before parsing the rest.
+func TestJSONReportMeta(t *testing.T) {
+	for _, style := range []jsonStyle{{}, {Full: true}, {Legacy: true}} {
+		r := fixtureReport()
+		r.Meta = newReportMeta([]string{"/data"}, 4, AggregateOptions{Days: 7, Model: "opus"}, "warn", style.Legacy)
+		data, err := indentJSON(r, style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out struct {
+			Meta *ReportMeta `json:"meta"`
+		}
+		if err := json.Unmarshal(data, &out); err != nil {
+			t.Fatal(err)
+		}
+		m := out.Meta
+		if m == nil {
+			t.Fatalf("%+v: no meta block", style)
+		}
+		want := schemaVersion
+		if style.Legacy {
+			want = 1
+		}
+		if m.SchemaVersion != want {
+			t.Errorf("%+v: schema_version = %d, want %d", style, m.SchemaVersion, want)
+		}
+		if _, err := time.Parse(time.RFC3339, m.GeneratedAt); err != nil {
+			t.Errorf("%+v: generated_at %q is not RFC3339", style, m.GeneratedAt)
+		}
+		if m.ToolVersion == "" || m.FileCount != 4 || len(m.ClaudeDir) != 1 {
+			t.Errorf("%+v: meta = %+v", style, m)
+		}
+		if f := m.Filters; f.Days != 7 || f.Model != "opus" || f.TZ != "UTC" || f.MinSeverity != "warn" {
+			t.Errorf("%+v: filters = %+v", style, f)
+		}
+		if !bytes.HasPrefix(data, []byte("{\n  \"meta\": {\n    \"schema_version\"")) {
+			t.Errorf("%+v: meta.schema_version does not open the report:\n%.80s", style, data)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// legacyKept lists JSON keys that were already snake_case before the report
+// types got json tags; --legacy-json leaves them alone. The meta block is new
+// with the snake_case schema and is copied unchanged, so .meta.schema_version
+// reads the same in every style.
+var legacyKept = map[string]bool{
+	"meta":                          true,
+	"type":                          true,
+	"period":                        true,
+	"daily_cost_usd":                true,
+	"daily_total_tokens":            true,
+	"api_requests":                  true,
+	"avg_cost_per_request":          true,
+	"avg_output_tokens_per_request": true,
+}
+
+// legacyNames maps each snake_case report key to the Go field name that
+// --json used before the switch. legacyMapKeys holds the keys whose values
+// are Go maps: their own keys are data (model IDs, project names), not
+// field names, and must not be renamed.
+var legacyNames, legacyMapKeys = buildLegacyNames(
+	reflect.TypeOf(AggregatedReport{}),
+	reflect.TypeOf(ndjsonSession{}),
+	reflect.TypeOf(UsageRecord{}),
+)
+
+func buildLegacyNames(roots ...reflect.Type) (names map[string]string, mapKeys map[string]bool) {
+	names = make(map[string]string)
+	mapKeys = make(map[string]bool)
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] || t.PkgPath() != reflect.TypeOf(UsageTotals{}).PkgPath() {
+			return
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			walk(f.Type)
+			key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if key == "" || key == "-" {
+				continue
+			}
+			if f.Type.Kind() == reflect.Map {
+				mapKeys[key] = true
+			}
+			if !legacyKept[key] {
+				names[key] = f.Name
+			}
+		}
+	}
+	for _, t := range roots {
+		walk(t)
+	}
+	return names, mapKeys
+}
+
+// legacyJSON rewrites compact report JSON to the pre-snake_case key names,
+// keeping key order. It backs --legacy-json.
+func legacyJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := rewriteLegacy(dec, &buf, false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rewriteLegacy copies one JSON value from dec to buf, renaming object keys
+// unless the object is a Go map (inMap).
+func rewriteLegacy(dec *json.Decoder, buf *bytes.Buffer, inMap bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			buf.WriteByte('{')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				kt, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := kt.(string)
+				valueIsMap := !inMap && legacyMapKeys[key]
+				if old, ok := legacyNames[key]; ok && !inMap {
+					key = old
+				}
+				writeJSONString(buf, key)
+				buf.WriteByte(':')
+				if key == "meta" && !inMap {
+					var raw json.RawMessage
+					if err := dec.Decode(&raw); err != nil {
+						return err
+					}
+					buf.Write(raw)
+					continue
+				}
+				if err := rewriteLegacy(dec, buf, valueIsMap); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte('}')
+		case '[':
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := rewriteLegacy(dec, buf, false); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		}
+		// Consume the closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	case string:
+		writeJSONString(buf, t)
+	case json.Number:
+		buf.WriteString(t.String())
+	case bool:
+		fmt.Fprint(buf, t)
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
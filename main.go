@@ -1,85 +1,538 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	days := flag.Int("days", 0, "Limit analysis to last N days (0 = all time)")
 	project := flag.String("project", "", "Filter by project name substring")
+	groupByWeek := flag.Bool("group-by-week", false, "Show the token trend per ISO week instead of per day")
 	jsonOut := flag.Bool("json", false, "Output machine-readable JSON to stdout")
+	legacyJSONOut := flag.Bool("legacy-json", false, "Use the old CamelCase JSON keys (InputTokens) instead of snake_case; will be removed next release")
+	jsonFull := flag.Bool("json-full", false, "Keep null, empty and unset fields in JSON output instead of omitting them")
+	format := flag.String("format", "text", "Output format: text, json (same as --json) or ndjson (one line per session, then a summary line)")
+	includeRecords := flag.Bool("include-records", false, "With --json, append a \"records\" array with one entry per counted message (output can be very large)")
+	recordsOut := flag.String("records-out", "", "Write one NDJSON line per counted message to this file (can be very large)")
+	oneline := flag.Bool("oneline", false, "Print one plain status line for today (or the --days window) and exit")
+	diffProjects := flag.String("diff-projects", "", "Compare two projects side by side: --diff-projects A,B (or --diff-projects A B as the last arguments)")
+	breakdown := flag.String("breakdown", "", "Print only one flat table and exit: date, model, project or session")
+	output := flag.String("output", "", "With --json or --format ndjson, write to this file (atomically) instead of stdout")
+	keep := flag.Int("keep", 0, "With --output, also keep dated copies (report-2006-01-02.json), pruned to the newest N")
+	emitNewline := flag.Bool("emit-newline", true, "End JSON output with exactly one trailing newline (use --emit-newline=false to omit it)")
+	trendMetric := flag.String("trend-metric", "tokens", "Scale the daily/weekly trend bars by tokens or cost")
+	sinceFirstUse := flag.Bool("since-first-use", false, "Start the daily/weekly trend at your first recorded session instead of padding earlier days")
+	verify := flag.Bool("verify", false, "Aggregate twice and warn about totals that differ by more than 0.1% between the passes")
+	noSubagents := flag.Bool("no-subagents", false, "Leave out subagent files; totals count only main-session messages")
+	fillDailyGaps := flag.Bool("fill-daily-gaps", false, "In all-time mode, show every day between the first and last record (up to 365) in the daily trend, not just active days")
+	noDelta := flag.Bool("no-delta", false, "Don't compare --days totals against the preceding window")
+	noClarity := flag.Bool("no-clarity", false, "Skip the prompt clarity analysis (saves a second pass over session files)")
+	sortBy := flag.String("sort", "tokens", "Order the PROJECTS table by tokens, cost or recency (most recently active first)")
+	topModels := flag.Int("top-models", 10, "Max models listed in the model breakdown table (0 = all)")
+	abbrev := flag.Bool("abbrev", false, "Abbreviate token counts as 1.2K / 3.4M / 1.1B / 1.0T and costs from $1,000 as $1.2K (JSON keeps exact values)")
+	flag.BoolVar(abbrev, "compact-numbers", false, "Same as --abbrev")
+	rawModelNames := flag.Bool("raw-model-names", false, "Show full model IDs instead of short names like \"Sonnet 4.5\"")
+	monthlyModels := flag.Bool("monthly-models", false, "Show a month-by-model-family cost matrix")
+	expandSessions := flag.Bool("expand-sessions", false, "Show each TOP SESSIONS row's time range and its top 3 models")
+	showSessions := flag.Bool("show-sessions", false, "List each project's top sessions under its row in PROJECTS")
+	top := flag.Int("top", 3, "Number of sessions listed per project with --show-sessions")
+	sidechainReport := flag.Bool("sidechain-report", false, "Add a SIDECHAIN BREAKDOWN section listing per-session sidechain usage")
+	minSeverity := flag.String("min-severity", "info", "Lowest insight severity to show: info or warn")
+	healthCheck := flag.Bool("health-check", false, "Exit 0 if the data directory has session data newer than --max-age-hours, else print why and exit 1")
+	maxAgeHours := flag.Int("max-age-hours", 24, "With --health-check, the oldest acceptable newest session file in hours (0 = any age)")
+	pricingTableOut := flag.Bool("model-pricing-table", false, "Print the built-in per-model token rates and exit")
 	serve := flag.Bool("serve", false, "Start local web UI server")
-	port := flag.Int("port", 8080, "Port for web UI server (used with --serve)")
-	claudeDir := flag.String("claude-dir", "", "Path to Claude data directory (default: ~/.claude)")
+	port := flag.Int("port", 8080, "Port for web UI server (used with --serve); the next free one is used if it is busy")
+	noBrowser := flag.Bool("no-browser", false, "Don't open the dashboard in a browser with --serve (skipped anyway without a display or a terminal)")
+	strictPort := flag.Bool("strict-port", false, "Fail instead of trying the next ports when --port is in use")
+	bind := flag.String("bind", "127.0.0.1", "Interface address for --serve; use 0.0.0.0 for all interfaces (needs --allow-remote or --auth-token)")
+	allowRemote := flag.Bool("allow-remote", false, "Let --serve bind a non-loopback address without --auth-token")
+	authToken := flag.String("auth-token", "", "Require this token on --serve API requests (?token=, Bearer header or cookie); \"auto\" generates one")
+	var corsOrigins stringList
+	flag.Var(&corsOrigins, "cors-origin", "Let pages from this origin (scheme://host[:port], or *) call the --serve API; repeatable or comma-separated (default: none)")
+	var claudeDirs stringList
+	flag.Var(&claudeDirs, "claude-dir", "Path to Claude data directory; repeatable or comma-separated (default: $CLAUDE_CONFIG_DIR, ~/.claude, or ~/.config/claude)")
+	mergeProjects := flag.Bool("merge-projects", false, "Merge identical project slugs across multiple --claude-dir directories")
+	summaryLine := flag.Bool("print-summary-line", false,
+		"After the report, write one stable key=value line for scripts:\n"+
+			"tokens=<int> cost_usd=<float> sessions=<int> cache_eff=<0..1> parse_errors=<int>")
+	summaryFD := flag.Int("summary-fd", 2, "File descriptor for --print-summary-line (2 = stderr)")
+	quiet := flag.Bool("quiet", false, "Don't show the parsing progress line on stderr")
+	verbose := flag.Bool("verbose", false, "Log diagnostic details to stderr")
+	profile := flag.String("profile", "", "Write a diagnostic profile: cpu, mem, trace, or a directory for cpu.pprof and heap.pprof; also prints phase timings")
+
+	// `completions` is a subcommand with its own flags; it needs the flags
+	// above registered to list them.
+	if len(os.Args) > 1 && os.Args[1] == "completions" {
+		os.Exit(runCompletions(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "help" {
+		os.Exit(runHelp(os.Args[2:]))
+	}
 	flag.Parse()
 
-	// Resolve Claude directory
-	dir := *claudeDir
-	if dir == "" {
-		home, err := os.UserHomeDir()
+	if err := startProfile(*profile); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	defer stopProfile()
+
+	if *trendMetric != "tokens" && *trendMetric != "cost" {
+		fmt.Fprintf(os.Stderr, "error: --trend-metric must be \"tokens\" or \"cost\", got %q\n", *trendMetric)
+		exit(2)
+	}
+
+	switch *format {
+	case "text":
+	case "json":
+		*jsonOut = true
+	case "ndjson":
+	default:
+		fmt.Fprintf(os.Stderr, "error: --format must be \"text\", \"json\" or \"ndjson\", got %q\n", *format)
+		exit(2)
+	}
+
+	var diffNames []string
+	if *diffProjects != "" {
+		diffNames = strings.Split(*diffProjects, ",")
+		if len(diffNames) == 1 && flag.NArg() == 1 {
+			diffNames = append(diffNames, flag.Arg(0))
+		}
+		if len(diffNames) != 2 || diffNames[0] == "" || diffNames[1] == "" {
+			fmt.Fprintln(os.Stderr, "error: --diff-projects needs two project names: --diff-projects A,B")
+			exit(2)
+		}
+	}
+
+	if *output != "" && ((!*jsonOut && *format != "ndjson") || diffNames != nil || *breakdown != "") {
+		fmt.Fprintln(os.Stderr, "error: --output only applies to the --json and --format ndjson reports")
+		exit(2)
+	}
+	if *keep < 0 || (*keep > 0 && *output == "") {
+		fmt.Fprintln(os.Stderr, "error: --keep needs --output and a positive count")
+		exit(2)
+	}
+
+	if *includeRecords && !*jsonOut {
+		fmt.Fprintln(os.Stderr, "error: --include-records requires --json (use --records-out for other formats)")
+		exit(2)
+	}
+
+	if *breakdown != "" && !slices.Contains(breakdownKinds, *breakdown) {
+		fmt.Fprintf(os.Stderr, "error: --breakdown must be one of %s, got %q\n", strings.Join(breakdownKinds, ", "), *breakdown)
+		exit(2)
+	}
+
+	if *sortBy != "tokens" && *sortBy != "cost" && *sortBy != "recency" {
+		fmt.Fprintf(os.Stderr, "error: --sort must be \"tokens\", \"cost\" or \"recency\", got %q\n", *sortBy)
+		exit(2)
+	}
+
+	if *minSeverity != "info" && *minSeverity != "warn" {
+		fmt.Fprintf(os.Stderr, "error: --min-severity must be \"info\" or \"warn\", got %q\n", *minSeverity)
+		exit(2)
+	}
+
+	if *pricingTableOut {
+		PrintPricingTable(os.Stdout, isTerminal())
+		exit(0)
+	}
+
+	style := jsonStyle{Legacy: *legacyJSONOut, Full: *jsonFull}
+
+	// Resolve Claude directories
+	var dirs []string
+	if len(claudeDirs) == 0 {
+		claudeDirs = stringList{""}
+	}
+	for _, d := range claudeDirs {
+		dir, source, err := resolveClaudeDir(d)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: cannot find home directory: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Use --claude-dir to specify an alternate path.\n")
+			exit(1)
+		}
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "using Claude data directory %s (from %s)\n", dir, source)
 		}
-		dir = filepath.Join(home, ".claude")
+		dirs = append(dirs, dir)
 	}
 
-	if _, err := os.Stat(dir); err != nil {
-		fmt.Fprintf(os.Stderr, "error: Claude data directory not found at %s\n", dir)
-		fmt.Fprintf(os.Stderr, "Use --claude-dir to specify an alternate path.\n")
-		os.Exit(1)
+	if *healthCheck {
+		failed := false
+		for _, dir := range dirs {
+			if err := HealthCheck(dir, *maxAgeHours); err != nil {
+				fmt.Fprintf(os.Stderr, "unhealthy: %v\n", err)
+				failed = true
+			}
+		}
+		if failed {
+			exit(1)
+		}
+		fmt.Println("ok")
+		exit(0)
 	}
 
 	opts := AggregateOptions{
-		Days:    *days,
-		Project: *project,
+		Days:             *days,
+		Project:          *project,
+		GroupByWeek:      *groupByWeek,
+		SkipClarity:      *noClarity || *breakdown != "",
+		NoDelta:          *noDelta,
+		SinceFirstUse:    *sinceFirstUse,
+		FillGaps:         *fillDailyGaps,
+		ExcludeSubagents: *noSubagents,
 	}
 
 	// --serve: hand off to the HTTP server, which re-aggregates on each request.
 	if *serve {
-		if err := ServeReport(dir, opts, *port); err != nil {
+		sopts := ServeOptions{Port: *port, StrictPort: *strictPort, NoBrowser: *noBrowser, Bind: *bind, AllowRemote: *allowRemote, Token: *authToken, CORSOrigins: corsOrigins}
+		// Ctrl+C or SIGTERM shuts the server down; a second one kills the
+		// process outright.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+		if err := ServeReport(ctx, dirs, *mergeProjects, opts, sopts); err != nil {
 			fmt.Fprintf(os.Stderr, "server error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 		return
 	}
 
 	// Terminal / JSON modes: aggregate once.
-	files, err := DiscoverFiles(dir)
+	discoverStart := time.Now()
+	files, skipped, err := DiscoverAll(dirs, *mergeProjects)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error discovering files: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+	if runTimings != nil {
+		runTimings.Discover = time.Since(discoverStart)
+	}
+
+	if *oneline {
+		runOneline(files, opts)
+		exit(0)
 	}
 
 	if len(files) == 0 {
 		fmt.Fprintln(os.Stderr, "No JSONL session files found. Have you used Claude Code yet?")
-		os.Exit(0)
+		exit(0)
 	}
 
-	opts.StatsCache = ParseStatsCache(dir)
+	opts.StatsCache = ParseStatsCacheAll(dirs)
+	opts.SkippedPaths = skipped
+
+	// Records are streamed to their file during aggregation. --include-records
+	// parks them in a temp file until the report is written.
+	var records *recordWriter
+	var recordsFile *os.File
+	if *recordsOut != "" || *includeRecords {
+		if *recordsOut != "" {
+			recordsFile, err = os.Create(*recordsOut)
+		} else {
+			recordsFile, err = os.CreateTemp("", "token-analyzer-records-*.ndjson")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		records = newRecordWriter(recordsFile, *legacyJSONOut)
+		opts.RecordSink = records.Write
+	}
+	finishRecords := func() {
+		if records == nil {
+			return
+		}
+		err := records.Flush()
+		if cerr := recordsFile.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing records: %v\n", err)
+			exit(1)
+		}
+		if *recordsOut != "" && !*quiet {
+			fmt.Fprintf(os.Stderr, "wrote %d records to %s\n", records.count, *recordsOut)
+		}
+	}
+
+	// toStdout runs write against stdout, or against the --output file.
+	toStdout := func(write func(io.Writer) error) error {
+		if *output == "" {
+			return write(os.Stdout)
+		}
+		return writeFileAtomic(*output, *keep, write)
+	}
+
+	if *format == "ndjson" {
+		meta := newReportMeta(dirs, len(files), opts, *minSeverity, *legacyJSONOut)
+		err := toStdout(func(w io.Writer) error {
+			return StreamNDJSON(w, files, opts, meta, *minSeverity, style)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding NDJSON: %v\n", err)
+			exit(1)
+		}
+		finishRecords()
+		exit(0)
+	}
+
+	var progress *progressLine
+	if !*quiet && isTTY(os.Stderr) {
+		progress = &progressLine{w: os.Stderr}
+		opts.Progress = progress.Update
+	}
 	report := Aggregate(files, opts)
+	if progress != nil {
+		progress.Clear()
+	}
+	finishRecords()
+
+	// --verify: read everything a second time and flag totals that moved,
+	// e.g. files rewritten mid-run.
+	if *verify {
+		vopts := opts
+		vopts.Progress = nil
+		vopts.RecordSink = nil
+		vopts.SkipClarity = true
+		for _, d := range Verify(report, Aggregate(files, vopts)) {
+			report.Insights = append(report.Insights, Insight{
+				Severity: "warn",
+				Message:  "Totals differ between two passes over the same files — " + d,
+			})
+		}
+	}
+	if runTimings != nil {
+		runTimings.Parse, runTimings.Aggregate, runTimings.Clarity = report.Timings.Parse, report.Timings.Aggregate, report.Timings.Clarity
+		runTimings.renderStart = time.Now()
+	}
 
 	if report.Grand.TotalTokens() == 0 {
+		if *includeRecords {
+			os.Remove(recordsFile.Name())
+		}
+		if *summaryLine {
+			writeSummaryLine(*summaryFD, report)
+		}
 		if *days > 0 {
 			fmt.Fprintf(os.Stderr, "No token data found in the last %d days.\n", *days)
 		} else {
 			fmt.Fprintln(os.Stderr, "No token data found.")
 		}
-		os.Exit(0)
+		exit(0)
 	}
 
-	if *jsonOut {
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		if err := enc.Encode(report); err != nil {
+	if diffNames != nil {
+		a, err := findProject(report, diffNames[0])
+		if err == nil {
+			var b *ProjectSummary
+			if b, err = findProject(report, diffNames[1]); err == nil {
+				diff := DiffProjects(a, b)
+				diff.addClarity(report.Clarity, a, b)
+				if *jsonOut {
+					err = writeJSON(os.Stdout, diff, *emitNewline, style)
+				} else {
+					PrintDiffProjects(os.Stdout, diff, isTerminal())
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+	} else if *breakdown != "" {
+		if err := PrintBreakdown(os.Stdout, report, *breakdown, isTerminal()); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+	} else if *jsonOut {
+		// Filter a copy so the report itself keeps every insight.
+		out := *report
+		out.Meta = newReportMeta(dirs, len(files), opts, *minSeverity, *legacyJSONOut)
+		out.Insights, out.SuppressedInsights = FilterInsights(report.Insights, *minSeverity)
+		err = toStdout(func(w io.Writer) error {
+			if *includeRecords {
+				return writeReportWithRecords(w, &out, recordsFile.Name(), *emitNewline, style)
+			}
+			return writeJSON(w, &out, *emitNewline, style)
+		})
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "error encoding JSON: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 	} else {
-		PrintReport(os.Stdout, report, isTerminal())
+		PrintReport(os.Stdout, report, isTerminal(), PrintOptions{
+			TopModels:          *topModels,
+			Width:              terminalWidth(),
+			RawModelNames:      *rawModelNames,
+			TrendMetric:        *trendMetric,
+			MonthlyModels:      *monthlyModels,
+			ShowSessions:       *showSessions,
+			SessionsPerProject: *top,
+			MinSeverity:        *minSeverity,
+			SidechainReport:    *sidechainReport,
+			Abbrev:             *abbrev,
+			SortBy:             *sortBy,
+			ExpandSessions:     *expandSessions,
+		})
+	}
+
+	if *summaryLine {
+		writeSummaryLine(*summaryFD, report)
+	}
+}
+
+// writeSummaryLine writes SummaryLine(r) to file descriptor fd.
+func writeSummaryLine(fd int, r *AggregatedReport) {
+	f := os.NewFile(uintptr(fd), "summary")
+	if f == nil {
+		fmt.Fprintf(os.Stderr, "error: --summary-fd %d is not a valid descriptor\n", fd)
+		return
+	}
+	if _, err := fmt.Fprintln(f, SummaryLine(r)); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing summary line: %v\n", err)
+	}
+}
+
+// runOneline prints the --oneline summary for today (UTC, matching the daily
+// trend) or the --days window. Files not modified since the window opened
+// can't hold records inside it, so they are skipped without being read; the
+// clarity pass and previous-period totals are skipped too.
+func runOneline(files []FileInfo, opts AggregateOptions) {
+	now := time.Now().UTC()
+	label := "today"
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if opts.Days > 0 {
+		label = fmt.Sprintf("%dd", opts.Days)
+		since = now.AddDate(0, 0, -opts.Days)
+	}
+	opts.SkipClarity = true
+	opts.NoDelta = true
+
+	report := Aggregate(ModifiedSince(files, since), opts)
+	totals := report.Grand
+	if opts.Days == 0 {
+		totals = UsageTotals{}
+		today := since.Format("2006-01-02")
+		for _, d := range report.AllDaily {
+			if d.Date == today {
+				totals = d.Totals
+			}
+		}
+	}
+	fmt.Println(OnelineSummary(label, totals))
+}
+
+// stringList is a flag.Value collecting repeated and comma-separated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
+
+// writeReportWithRecords writes the --json --include-records document to w,
+// splicing in the records parked at path, then removes path.
+func writeReportWithRecords(w io.Writer, report *AggregatedReport, path string, newline bool, style jsonStyle) error {
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeJSONWithRecords(w, report, f, newline, style)
+}
+
+// writeJSON writes v as indented JSON in the given style. When newline is
+// true the output ends with exactly one '\n'; otherwise it ends at the
+// closing brace.
+func writeJSON(w io.Writer, v any, newline bool, style jsonStyle) error {
+	data, err := indentJSON(v, style)
+	if err != nil {
+		return err
+	}
+	if newline {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// indentJSON encodes v with two-space indentation and no trailing newline.
+func indentJSON(v any, style jsonStyle) ([]byte, error) {
+	data, err := encodeJSON(v, style)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// resolveClaudeDir picks the Claude data directory. An explicit --claude-dir
+// always wins; otherwise $CLAUDE_CONFIG_DIR, ~/.claude and ~/.config/claude are
+// tried in order and the first containing a projects/ subdirectory is used.
+// The second return value names where the directory came from.
+func resolveClaudeDir(flagDir string) (string, string, error) {
+	if flagDir != "" {
+		if _, err := os.Stat(flagDir); err != nil {
+			return "", "", fmt.Errorf("Claude data directory not found at %s", flagDir)
+		}
+		return flagDir, "--claude-dir", nil
+	}
+
+	type candidate struct {
+		dir, source string
+	}
+	var candidates []candidate
+	if env := os.Getenv("CLAUDE_CONFIG_DIR"); env != "" {
+		candidates = append(candidates, candidate{env, "$CLAUDE_CONFIG_DIR"})
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates,
+			candidate{filepath.Join(home, ".claude"), "~/.claude"},
+			candidate{filepath.Join(home, ".config", "claude"), "~/.config/claude"},
+		)
+	}
+	if len(candidates) == 0 {
+		return "", "", fmt.Errorf("cannot find home directory")
+	}
+
+	for _, c := range candidates {
+		if fi, err := os.Stat(filepath.Join(c.dir, "projects")); err == nil && fi.IsDir() {
+			return c.dir, c.source, nil
+		}
+	}
+
+	var tried []string
+	for _, c := range candidates {
+		tried = append(tried, c.dir)
 	}
+	return "", "", fmt.Errorf("no Claude data directory found (tried %s)", strings.Join(tried, ", "))
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// schemaVersion identifies the shape of the JSON report. Bump it whenever a
+// field is renamed, removed or changes meaning; adding fields does not
+// require a bump.
+//
+//	1: Go field names as keys (still available via --legacy-json)
+//	2: snake_case keys, top-level "meta" block
+//	3: null, empty and unset fields are omitted unless --json-full;
+//	   peak_hour is null/absent instead of -1 when unknown
+//	4: clarity.front_load_by_project is keyed by project key (data
+//	   directory + slug, as score_by_project's new project_key) rather than
+//	   by project name, so projects sharing a name stay apart
+const schemaVersion = 4
+
+// version is the tool version reported in JSON output. Release builds set it
+// with -ldflags "-X main.version=v1.2.3"; otherwise the module version from
+// the build info is used when there is one.
+var version = "dev"
+
+// ReportMeta describes how a JSON report was produced, so saved reports can
+// be told apart and parsed by the right schema.
+type ReportMeta struct {
+	SchemaVersion int           `json:"schema_version"`
+	GeneratedAt   string        `json:"generated_at"` // RFC3339
+	ToolVersion   string        `json:"tool_version"`
+	ClaudeDir     []string      `json:"claude_dir"` // every --claude-dir analyzed
+	FileCount     int           `json:"file_count"` // JSONL files discovered, including subagents
+	Filters       ReportFilters `json:"filters"`
+}
+
+// ReportFilters records the options that narrowed the report.
+type ReportFilters struct {
+	Days        int    `json:"days"`         // 0 = all time
+	Project     string `json:"project"`      // substring filter; empty = all projects
+	Model       string `json:"model"`        // substring filter; empty = all models
+	TZ          string `json:"tz"`           // zone used for daily buckets
+	NoSubagents bool   `json:"no_subagents"` // subagent files were left out
+	MinSeverity string `json:"min_severity"` // insights below this were suppressed
+}
+
+func newReportMeta(dirs []string, fileCount int, opts AggregateOptions, minSeverity string, legacy bool) *ReportMeta {
+	m := &ReportMeta{
+		SchemaVersion: schemaVersion,
+		GeneratedAt:   time.Now().Format(time.RFC3339),
+		ToolVersion:   toolVersion(),
+		ClaudeDir:     dirs,
+		FileCount:     fileCount,
+		Filters: ReportFilters{
+			Days:        opts.Days,
+			Project:     opts.Project,
+			Model:       opts.Model,
+			NoSubagents: opts.ExcludeSubagents,
+			TZ:          "UTC",
+			MinSeverity: minSeverity,
+		},
+	}
+	if opts.Location != nil {
+		m.Filters.TZ = opts.Location.String()
+	}
+	if legacy {
+		m.SchemaVersion = 1
+	}
+	return m
+}
+
+func toolVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxMetricProjects bounds the project label on /metrics; smaller projects
+// are summed under project=otherProjectLabel.
+const maxMetricProjects = 10
+
+// otherProjectLabel labels the summed smaller projects. The underscores keep
+// it apart from a real project called "other".
+const otherProjectLabel = "__other__"
+
+// processMetrics are the server-level figures exported on /metrics.
+type processMetrics struct {
+	Uptime          time.Duration
+	Aggregations    int64
+	LastDuration    time.Duration
+	LastAggregation time.Time
+}
+
+// writeMetrics writes r and pm in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, r *AggregatedReport, pm processMetrics) error {
+	bw := bufio.NewWriter(w)
+	family := func(name, typ, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	sample := func(name string, v float64, labels ...string) {
+		bw.WriteString(name)
+		if len(labels) > 0 {
+			bw.WriteByte('{')
+			for i := 0; i+1 < len(labels); i += 2 {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				fmt.Fprintf(bw, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+			}
+			bw.WriteByte('}')
+		}
+		fmt.Fprintf(bw, " %g\n", v)
+	}
+
+	models := make([]string, 0, len(r.ModelSummaries))
+	for m := range r.ModelSummaries {
+		models = append(models, m)
+	}
+	sort.Strings(models)
+
+	family("token_analyzer_tokens", "gauge", "Tokens in the report window by type and model.")
+	for _, m := range models {
+		t := r.ModelSummaries[m]
+		sample("token_analyzer_tokens", float64(t.InputTokens), "type", "input", "model", m)
+		sample("token_analyzer_tokens", float64(t.OutputTokens), "type", "output", "model", m)
+		sample("token_analyzer_tokens", float64(t.CacheCreationInputTokens), "type", "cache_write", "model", m)
+		sample("token_analyzer_tokens", float64(t.CacheReadInputTokens), "type", "cache_read", "model", m)
+	}
+
+	family("token_analyzer_cost_usd", "gauge", "Estimated cost in USD in the report window by model.")
+	for _, m := range models {
+		sample("token_analyzer_cost_usd", r.ModelSummaries[m].CostUSD, "model", m)
+	}
+
+	// Projects in different directories can share a name; a label set must
+	// be unique, so they are summed by name first.
+	byName := make(map[string]*UsageTotals)
+	var names []string
+	for _, proj := range r.Projects {
+		if byName[proj.Name] == nil {
+			byName[proj.Name] = &UsageTotals{}
+			names = append(names, proj.Name)
+		}
+		byName[proj.Name].Merge(proj.Totals)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return byName[names[i]].TotalTokens() > byName[names[j]].TotalTokens()
+	})
+	var other UsageTotals
+	for _, name := range names[min(len(names), maxMetricProjects):] {
+		other.Merge(*byName[name])
+	}
+	top := names[:min(len(names), maxMetricProjects)]
+
+	family("token_analyzer_project_tokens", "gauge", "Tokens in the report window by project (top projects, rest as \"__other__\").")
+	for _, name := range top {
+		sample("token_analyzer_project_tokens", float64(byName[name].TotalTokens()), "project", name)
+	}
+	if len(names) > maxMetricProjects {
+		sample("token_analyzer_project_tokens", float64(other.TotalTokens()), "project", otherProjectLabel)
+	}
+	family("token_analyzer_project_cost_usd", "gauge", "Estimated cost in USD by project (top projects, rest as \"__other__\").")
+	for _, name := range top {
+		sample("token_analyzer_project_cost_usd", byName[name].CostUSD, "project", name)
+	}
+	if len(names) > maxMetricProjects {
+		sample("token_analyzer_project_cost_usd", other.CostUSD, "project", otherProjectLabel)
+	}
+
+	family("token_analyzer_sessions", "gauge", "Sessions in the report window.")
+	sample("token_analyzer_sessions", float64(len(r.Sessions)))
+	family("token_analyzer_cache_efficiency", "gauge", "Cache reads over all input-side tokens (0-1).")
+	sample("token_analyzer_cache_efficiency", r.Grand.CacheEfficiency())
+	family("token_analyzer_parse_errors", "gauge", "Malformed lines skipped in the last aggregation.")
+	sample("token_analyzer_parse_errors", float64(r.ParseErrors))
+	family("token_analyzer_files", "gauge", "Session and subagent files analyzed.")
+	sample("token_analyzer_files", float64(r.MainFileCount), "kind", "session")
+	sample("token_analyzer_files", float64(r.SubagentFileCount), "kind", "subagent")
+
+	family("token_analyzer_aggregations_total", "counter", "Aggregations run by this server.")
+	sample("token_analyzer_aggregations_total", float64(pm.Aggregations))
+	family("token_analyzer_aggregation_duration_seconds", "gauge", "Duration of the last aggregation.")
+	sample("token_analyzer_aggregation_duration_seconds", pm.LastDuration.Seconds())
+	if !pm.LastAggregation.IsZero() {
+		family("token_analyzer_last_aggregation_timestamp_seconds", "gauge", "Unix time of the last aggregation.")
+		sample("token_analyzer_last_aggregation_timestamp_seconds", float64(pm.LastAggregation.Unix()))
+	}
+	family("token_analyzer_uptime_seconds", "gauge", "Seconds since the server started.")
+	sample("token_analyzer_uptime_seconds", pm.Uptime.Seconds())
+
+	return bw.Flush()
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// A real project called "other" must not collide with the overflow series.
+func TestWriteMetricsOtherProject(t *testing.T) {
+	r := &AggregatedReport{ModelSummaries: map[string]*UsageTotals{}}
+	r.Projects = append(r.Projects, &ProjectSummary{Name: "other", Totals: UsageTotals{InputTokens: 1000}})
+	for i := 1; i <= maxMetricProjects+1; i++ {
+		r.Projects = append(r.Projects, &ProjectSummary{Name: fmt.Sprintf("p%02d", i), Totals: UsageTotals{InputTokens: int64(100 - i)}})
+	}
+
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, r, processMetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "token_analyzer_project_tokens{") {
+			continue
+		}
+		series := line[:strings.LastIndexByte(line, ' ')]
+		if seen[series] {
+			t.Errorf("duplicate series %s", series)
+		}
+		seen[series] = true
+	}
+	for _, want := range []string{
+		`token_analyzer_project_tokens{project="other"} 1000`,
+		`token_analyzer_project_tokens{project="__other__"} 179`, // p10 (90) + p11 (89)
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
 	"time"
 )
 
@@ -45,6 +48,7 @@ type MessageRecord struct {
 	Slug        string      `json:"slug"`
 	GitBranch   string      `json:"gitBranch"`
 	Message     MessageBody `json:"message"`
+	Summary     string      `json:"summary"` // session title, on "summary" records only
 }
 
 // ---- File classification ----
@@ -54,7 +58,7 @@ type FileKind int
 
 const (
 	KindSession  FileKind = iota // <slug>/<uuid>.jsonl
-	KindSubagent                 // <slug>/<uuid>/subagents/agent-<id>.jsonl
+	KindSubagent                 // <slug>/<uuid>/**/agent-<id>.jsonl (usually subagents/)
 )
 
 // FileInfo describes a discovered JSONL file.
@@ -64,28 +68,107 @@ type FileInfo struct {
 	ProjectSlug string
 	SessionID   string
 	AgentID     string // empty for KindSession
+	Root        string // data directory this file came from; empty when projects may merge across directories
+}
+
+// ProjectKey identifies the project this file belongs to. Files from different
+// data directories only share a project when Root is empty.
+func (fi FileInfo) ProjectKey() string {
+	if fi.Root == "" {
+		return fi.ProjectSlug
+	}
+	return fi.Root + string(filepath.Separator) + fi.ProjectSlug
 }
 
 // ---- Aggregated types ----
 
 // UsageTotals is the canonical accumulator for any aggregation axis.
 type UsageTotals struct {
-	InputTokens              int64
-	OutputTokens             int64
-	CacheCreationInputTokens int64
-	CacheReadInputTokens     int64
-	MessageCount             int64
-	CostUSD                  float64
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	MessageCount             int64   `json:"message_count"`
+	CostUSD                  float64 `json:"cost_usd"`
+
+	// Cache components of CostUSD, and what the cached tokens would have
+	// cost at the plain input rate.
+	CacheWriteCostUSD    float64 `json:"cache_write_cost_usd"`
+	CacheReadCostUSD     float64 `json:"cache_read_cost_usd"`
+	CacheUncachedCostUSD float64 `json:"cache_uncached_cost_usd"`
 }
 
-// Add merges a TokenUsage into this accumulator.
-func (t *UsageTotals) Add(u TokenUsage, cost float64) {
+// Add merges a TokenUsage and its cost into this accumulator.
+func (t *UsageTotals) Add(u TokenUsage, cost CostBreakdown) {
 	t.InputTokens += int64(u.InputTokens)
 	t.OutputTokens += int64(u.OutputTokens)
 	t.CacheCreationInputTokens += int64(u.CacheCreationInputTokens)
 	t.CacheReadInputTokens += int64(u.CacheReadInputTokens)
 	t.MessageCount++
-	t.CostUSD += cost
+	t.CostUSD += cost.Total()
+	t.CacheWriteCostUSD += cost.CacheWrite
+	t.CacheReadCostUSD += cost.CacheRead
+	t.CacheUncachedCostUSD += cost.CacheUncached
+}
+
+// Merge adds another accumulator's counts into this one.
+func (t *UsageTotals) Merge(o UsageTotals) {
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.CacheCreationInputTokens += o.CacheCreationInputTokens
+	t.CacheReadInputTokens += o.CacheReadInputTokens
+	t.MessageCount += o.MessageCount
+	t.CostUSD += o.CostUSD
+	t.CacheWriteCostUSD += o.CacheWriteCostUSD
+	t.CacheReadCostUSD += o.CacheReadCostUSD
+	t.CacheUncachedCostUSD += o.CacheUncachedCostUSD
+}
+
+// Sub returns t minus o, field by field. Negative results are kept: they
+// mean o was larger (e.g. usage declined against a previous period).
+func (t UsageTotals) Sub(o UsageTotals) UsageTotals {
+	return UsageTotals{
+		InputTokens:              t.InputTokens - o.InputTokens,
+		OutputTokens:             t.OutputTokens - o.OutputTokens,
+		CacheCreationInputTokens: t.CacheCreationInputTokens - o.CacheCreationInputTokens,
+		CacheReadInputTokens:     t.CacheReadInputTokens - o.CacheReadInputTokens,
+		MessageCount:             t.MessageCount - o.MessageCount,
+		CostUSD:                  t.CostUSD - o.CostUSD,
+		CacheWriteCostUSD:        t.CacheWriteCostUSD - o.CacheWriteCostUSD,
+		CacheReadCostUSD:         t.CacheReadCostUSD - o.CacheReadCostUSD,
+		CacheUncachedCostUSD:     t.CacheUncachedCostUSD - o.CacheUncachedCostUSD,
+	}
+}
+
+// Div returns t with every field divided by n, e.g. to average over days or
+// sessions. Integer fields are rounded to the nearest whole number. A zero n
+// yields the zero value rather than dividing by zero.
+func (t UsageTotals) Div(n float64) UsageTotals {
+	if n == 0 {
+		return UsageTotals{}
+	}
+	div := func(v int64) int64 { return int64(math.Round(float64(v) / n)) }
+	return UsageTotals{
+		InputTokens:              div(t.InputTokens),
+		OutputTokens:             div(t.OutputTokens),
+		CacheCreationInputTokens: div(t.CacheCreationInputTokens),
+		CacheReadInputTokens:     div(t.CacheReadInputTokens),
+		MessageCount:             div(t.MessageCount),
+		CostUSD:                  t.CostUSD / n,
+		CacheWriteCostUSD:        t.CacheWriteCostUSD / n,
+		CacheReadCostUSD:         t.CacheReadCostUSD / n,
+		CacheUncachedCostUSD:     t.CacheUncachedCostUSD / n,
+	}
+}
+
+// PerRequest returns the average cost and output tokens per API request
+// (one assistant message). Both are 0 when there are no requests.
+func (t UsageTotals) PerRequest() (cost, output float64) {
+	if t.MessageCount == 0 {
+		return 0, 0
+	}
+	n := float64(t.MessageCount)
+	return t.CostUSD / n, float64(t.OutputTokens) / n
 }
 
 // TotalTokens returns the sum of all token types.
@@ -104,26 +187,59 @@ func (t UsageTotals) CacheEfficiency() float64 {
 
 // ProjectSummary aggregates all token usage for one project.
 type ProjectSummary struct {
-	Slug           string
-	Name           string
-	Path           string
-	Totals         UsageTotals
-	SessionCount   int
-	SubagentCount  int
-	ModelBreakdown map[string]*UsageTotals
-	Sessions       []*SessionSummary
+	Slug           string                  `json:"slug"`
+	Name           string                  `json:"name"`
+	Path           string                  `json:"path"`
+	DataDir        string                  `json:"data_dir"` // set only when multiple unmerged data directories are analyzed
+	Totals         UsageTotals             `json:"totals"`
+	SessionCount   int                     `json:"session_count"`
+	SubagentCount  int                     `json:"subagent_count"`
+	ModelBreakdown map[string]*UsageTotals `json:"model_breakdown"`
+	Sessions       []*SessionSummary       `json:"sessions"`
+	LastActiveTime time.Time               `json:"last_active_time"` // latest record timestamp, i.e. max session EndTime
 }
 
 // SessionSummary aggregates token usage for one session UUID.
 type SessionSummary struct {
-	SessionID      string
-	ProjectName    string
-	ProjectSlug    string
-	StartTime      time.Time
-	EndTime        time.Time
-	Totals         UsageTotals // main conversation only
-	SubagentTotals UsageTotals // tokens from subagent files for this session
-	ModelBreakdown map[string]*UsageTotals
+	SessionID      string                  `json:"session_id"`
+	ProjectName    string                  `json:"project_name"`
+	ProjectSlug    string                  `json:"project_slug"`
+	StartTime      time.Time               `json:"start_time"`
+	EndTime        time.Time               `json:"end_time"`
+	Totals         UsageTotals             `json:"totals"`          // main conversation only
+	SubagentTotals UsageTotals             `json:"subagent_totals"` // tokens from subagent files for this session
+	ModelBreakdown map[string]*UsageTotals `json:"model_breakdown"`
+
+	// Records flagged isSidechain, from either kind of file. Already counted
+	// in Totals or SubagentTotals; this is a separate view, not an addition.
+	SidechainTotals UsageTotals `json:"sidechain_totals"`
+
+	GitBranch   string   `json:"git_branch"`   // most common branch across the session's records
+	Branches    []string `json:"branches"`     // every distinct branch seen, sorted
+	BranchCount int      `json:"branch_count"` // len(Branches); > 1 means the session switched branches
+
+	DurationSeconds int64 `json:"duration_seconds"` // EndTime - StartTime
+	MessageCount    int64 `json:"message_count"`    // assistant messages in the main conversation
+
+	UserMessageCount int   `json:"user_message_count"` // real user prompts (excludes tool results)
+	ToolResultCount  int   `json:"tool_result_count"`  // user records carrying tool_result blocks
+	ToolResultBytes  int64 `json:"tool_result_bytes"`  // total text size of those tool results
+
+	projectKey string // FileInfo.ProjectKey of the owning project
+}
+
+// Duration returns how long the session ran, or 0 if times are unknown.
+// Key identifies p across data directories: its data directory and slug.
+// Per-project clarity results are keyed the same way.
+func (p *ProjectSummary) Key() string {
+	return p.DataDir + p.Slug
+}
+
+func (s *SessionSummary) Duration() time.Duration {
+	if s.StartTime.IsZero() || s.EndTime.IsZero() {
+		return 0
+	}
+	return s.EndTime.Sub(s.StartTime)
 }
 
 // CombinedTokens returns total tokens including subagents.
@@ -133,74 +249,234 @@ func (s *SessionSummary) CombinedTokens() int64 {
 
 // DailySummary aggregates token usage for a calendar date.
 type DailySummary struct {
-	Date   string // "YYYY-MM-DD"
-	Totals UsageTotals
+	Date   string      `json:"date"` // "YYYY-MM-DD"
+	Totals UsageTotals `json:"totals"`
+
+	// Redundant copies of Totals fields so JSON consumers don't need to
+	// reach into the nested struct.
+	DailyCostUSD     float64 `json:"daily_cost_usd"`
+	DailyTotalTokens int64   `json:"daily_total_tokens"`
+
+	// Filled marks a zero day inserted by --fill-daily-gaps.
+	Filled bool `json:"filled,omitempty"`
+}
+
+// WeeklySummary aggregates token usage for one ISO week.
+type WeeklySummary struct {
+	WeekLabel string      `json:"week_label"` // "W01 2025"
+	Totals    UsageTotals `json:"totals"`
+	DayTokens [7]int64    `json:"day_tokens"` // total tokens per day, Monday first
+}
+
+// DailyModelSummary holds one day's usage split by model ID, for stacked
+// per-model charts.
+type DailyModelSummary struct {
+	Date   string                  `json:"date"` // "YYYY-MM-DD"
+	Models map[string]*UsageTotals `json:"models"`
+}
+
+// HourlySummary aggregates usage by hour of day across the whole window.
+type HourlySummary struct {
+	Hour   int         `json:"hour"` // 0-23 in the report's zone
+	Totals UsageTotals `json:"totals"`
+}
+
+// MonthlyModelEntry holds one model's usage within one calendar month.
+type MonthlyModelEntry struct {
+	Month  string      `json:"month"` // "2006-01"
+	Model  string      `json:"model"`
+	Totals UsageTotals `json:"totals"`
 }
 
 // Insight is a single actionable observation surfaced in the report.
 type Insight struct {
-	Severity string // "good", "info", "warn"
-	Message  string
+	Severity string `json:"severity"` // "good", "info", "warn"
+	Message  string `json:"message"`
 }
 
 // ClarityMetrics holds the aggregate prompt clarity measurements.
 type ClarityMetrics struct {
-	CorrectionRate    float64
-	ClarificationRate float64
-	FrontLoadRatio    float64
-	Score             float64
-	CorrectionsByType map[string]float64 // "scope"->rate, "format"->rate, "intent"->rate
+	CorrectionRate    float64            `json:"correction_rate"`
+	ClarificationRate float64            `json:"clarification_rate"`
+	FrontLoadRatio    float64            `json:"front_load_ratio"`
+	Score             float64            `json:"score"`
+	CorrectionsByType map[string]float64 `json:"corrections_by_type"` // "scope"->rate, "format"->rate, "intent"->rate
+
+	// FirstMessageWordsAvg is the mean word count of each scored session's
+	// first prompt.
+	FirstMessageWordsAvg float64 `json:"first_message_words_avg"`
 }
 
 // WeeklyClarity holds clarity metrics for one ISO week (Monday-based).
 type WeeklyClarity struct {
-	WeekStart         string // "YYYY-MM-DD" Monday
-	CorrectionRate    float64
-	ClarificationRate float64
-	FrontLoadRatio    float64
-	Score             float64
-	SessionCount      int
+	WeekStart         string  `json:"week_start"` // "YYYY-MM-DD" Monday
+	CorrectionRate    float64 `json:"correction_rate"`
+	ClarificationRate float64 `json:"clarification_rate"`
+	FrontLoadRatio    float64 `json:"front_load_ratio"`
+	Score             float64 `json:"score"`
+	SessionCount      int     `json:"session_count"`
 }
 
 // HourlyClarityBucket holds the average clarity score for one hour of day (local time).
 // Score is -1 if no sessions started in that hour.
 type HourlyClarityBucket struct {
-	Hour         int     // 0-23 local time
-	Score        float64 // avg clarity score; -1 if no sessions
-	SessionCount int
+	Hour         int     `json:"hour"`  // 0-23 local time
+	Score        float64 `json:"score"` // avg clarity score; -1 if no sessions
+	SessionCount int     `json:"session_count"`
+}
+
+// ProjectClarityRank is one project's mean clarity score across its sessions.
+type ProjectClarityRank struct {
+	ProjectKey   string  `json:"project_key"` // ProjectSummary.Key of the project
+	ProjectName  string  `json:"project_name"`
+	Score        float64 `json:"score"`
+	SessionCount int     `json:"session_count"`
 }
 
 // ClarityReport is the top-level clarity result attached to AggregatedReport.
 type ClarityReport struct {
-	Overall       ClarityMetrics
-	Weekly        []WeeklyClarity      // sorted asc by WeekStart
-	SessionCount  int
-	Tips          []*CoachingTip       // nil if all metrics good or < 2 sessions
-	ScoreDelta    *float64             // last week minus previous week; nil if < 2 weeks
-	HourlyBuckets []HourlyClarityBucket // 24 entries, ordered 0–23
-	BestHour      int                  // local hour with highest avg score; -1 if no data
-	WorstHour     int                  // local hour with lowest avg score; -1 if no data
+	Overall             ClarityMetrics        `json:"overall"`
+	Weekly              []WeeklyClarity       `json:"weekly"`                // sorted asc by WeekStart
+	ScoreByProject      []ProjectClarityRank  `json:"score_by_project"`      // sorted asc by Score (worst first)
+	FrontLoadByProject  map[string]float64    `json:"front_load_by_project"` // mean FrontLoadRatio per project key (see ProjectName)
+	SessionCount        int                   `json:"session_count"`         // sessions with at least one real prompt
+	ScoredSessionCount  int                   `json:"scored_session_count"`  // those with MinSessionMessages+ prompts; the metrics average these
+	AgenticSessionCount int                   `json:"agentic_session_count"` // scored sessions over 80% tool results, left out of FrontLoadRatio
+	ToolCallRate        float64               `json:"tool_call_rate"`        // share of assistant messages with a tool_use block
+	AvgResponseLength   float64               `json:"avg_response_length"`   // mean per-session assistant reply length in characters
+	Tips                []*CoachingTip        `json:"tips"`                  // nil if all metrics good or < 2 sessions
+	ScoreDelta          *float64              `json:"score_delta"`           // last week minus previous week; nil if < 2 weeks
+	HourlyBuckets       []HourlyClarityBucket `json:"hourly_buckets"`        // 24 entries, ordered 0–23
+	BestHour            int                   `json:"best_hour"`             // local hour with highest avg score; -1 if no data
+	WorstHour           int                   `json:"worst_hour"`            // local hour with lowest avg score; -1 if no data
+
+	// ClarificationPhraseFrequency counts, over scored sessions, the
+	// clarificationSignals phrases found in the model's first reply; the
+	// ten most frequent are kept.
+	ClarificationPhraseFrequency map[string]int `json:"clarification_phrase_frequency"`
+}
+
+// ProjectName returns the display name of the project with key, one of the
+// FrontLoadByProject keys, or key itself if no scored project has it.
+func (c *ClarityReport) ProjectName(key string) string {
+	for _, pr := range c.ScoreByProject {
+		if pr.ProjectKey == key {
+			return pr.ProjectName
+		}
+	}
+	return key
 }
 
 // AggregatedReport is the top-level result from the aggregation phase.
 type AggregatedReport struct {
-	Grand          UsageTotals
-	ModelSummaries map[string]*UsageTotals
-	Projects       []*ProjectSummary // sorted by TotalTokens desc
-	Sessions       []*SessionSummary // sorted by CombinedTokens desc
-	Daily          []DailySummary    // sorted by date asc
-	ParseErrors    int
-	Insights       []Insight
-	DateFrom       time.Time
-	DateTo         time.Time
-	FilterDays     int
-	FilterProject  string
-	PeakHour       int // -1 if unknown
-	Clarity        *ClarityReport
+	Meta                  *ReportMeta             `json:"meta,omitempty"` // set by callers that emit JSON
+	Grand                 UsageTotals             `json:"grand"`
+	Previous              *UsageTotals            `json:"previous"`      // preceding window of the same length; nil without --days
+	GrandByType           map[string]*UsageTotals `json:"grand_by_type"` // "main" and "subagent"; sums to Grand
+	ModelSummaries        map[string]*UsageTotals `json:"model_summaries"`
+	Projects              []*ProjectSummary       `json:"projects"`                // sorted by TotalTokens desc
+	Sessions              []*SessionSummary       `json:"sessions"`                // sorted by CombinedTokens desc
+	Daily                 []DailySummary          `json:"daily"`                   // sorted by date asc
+	Weekly                []WeeklySummary         `json:"weekly"`                  // only with --group-by-week; sorted asc
+	AllDaily              []DailySummary          `json:"all_daily"`               // every active day, untrimmed; sorted by date asc
+	DailyByModel          []DailyModelSummary     `json:"daily_by_model"`          // every active day, split by model; sorted by date asc
+	Hourly                []HourlySummary         `json:"hourly"`                  // 24 entries, by hour of day in the report's zone
+	MonthlyModelBreakdown []MonthlyModelEntry     `json:"monthly_model_breakdown"` // sorted by month, then model
+	UniqueSessionCount    int                     `json:"unique_session_count"`    // distinct session UUIDs across all files
+	MainFileCount         int                     `json:"main_file_count"`         // session JSONL files analyzed
+	SubagentFileCount     int                     `json:"subagent_file_count"`     // subagent JSONL files analyzed
+	ParseErrors           int                     `json:"parse_errors"`
+	PartialFiles          int                     `json:"partial_files"`           // files ending in a half-written line (live sessions)
+	SkippedPaths          []string                `json:"skipped_paths,omitempty"` // unreadable paths found during discovery
+	Insights              []Insight               `json:"insights"`
+	SuppressedInsights    int                     `json:"suppressed_insights,omitempty"` // hidden by --min-severity; Insights holds the rest
+	DateFrom              time.Time               `json:"date_from"`
+	DateTo                time.Time               `json:"date_to"`
+	FilterDays            int                     `json:"filter_days"`
+	FilterProject         string                  `json:"filter_project"`
+	NoSubagents           bool                    `json:"no_subagents,omitempty"`   // subagent files were excluded (--no-subagents)
+	PeakHour              *int                    `json:"peak_hour"`                // local hour from stats-cache; nil if unknown
+	Clarity               *ClarityReport          `json:"clarity"`                  // nil when AggregateOptions.SkipClarity is set
+	Timings               PhaseTimings            `json:"-"`                        // Parse, Aggregate and Clarity of this run
+	Period                string                  `json:"period"`                   // DateRange(), for JSON consumers
+	Reconciliation        *Reconciliation         `json:"reconciliation,omitempty"` // nil without stats-cache.json
+
+	// Per-request figures derived from Grand, for checking against console
+	// request counts.
+	APIRequests         int64   `json:"api_requests"`
+	AvgCostPerRequest   float64 `json:"avg_cost_per_request"`
+	AvgOutputPerRequest float64 `json:"avg_output_tokens_per_request"`
+
+	// CacheSavingsUSD is what cache reads saved over paying the input rate
+	// for the same tokens, summed per model.
+	CacheSavingsUSD float64 `json:"cache_savings_usd"`
+
+	// Distribution of session size, main plus subagent, since a few huge
+	// sessions make the mean misleading. Zero when there are no sessions.
+	Percentiles     Percentiles     `json:"percentiles"`
+	CostPercentiles CostPercentiles `json:"cost_percentiles"`
+
+	// FirstUseDate is the oldest record regardless of --days (unlike
+	// DateFrom), and DaysSinceFirstUse the whole days from it to now.
+	FirstUseDate      time.Time `json:"first_use_date"`
+	DaysSinceFirstUse int       `json:"days_since_first_use"`
+
+	// First and last record per model ID, like FirstUseDate ignoring --days
+	// so migrations between models show up in any window.
+	ModelFirstSeen map[string]time.Time `json:"model_first_seen"`
+	ModelLastSeen  map[string]time.Time `json:"model_last_seen"`
+}
+
+// Percentiles summarizes per-session token counts (nearest-rank).
+type Percentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+}
+
+// CostPercentiles summarizes per-session USD cost (nearest-rank).
+type CostPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// DateRange describes the analyzed period: "No data", "Last N days", or
+// "Jan 02, 2006 – Jan 09, 2006".
+func (r *AggregatedReport) DateRange() string {
+	if r.DateFrom.IsZero() && r.DateTo.IsZero() {
+		return "No data"
+	}
+	if r.FilterDays > 0 {
+		return fmt.Sprintf("Last %d days", r.FilterDays)
+	}
+	return fmtDate(r.DateFrom) + " – " + fmtDate(r.DateTo)
 }
 
 // ---- stats-cache.json types ----
 
+// Reconciliation compares the totals computed from session files with the
+// per-model figures Claude Code keeps in stats-cache.json.
+type Reconciliation struct {
+	Models []ModelReconciliation `json:"models"` // union of both sources, sorted by model ID
+	Total  ModelReconciliation   `json:"total"`  // sums over Models; Model is empty
+	// Filtered is set when --days or --project narrowed the computed side.
+	// stats-cache.json is all-time, so differences are then expected.
+	Filtered bool `json:"filtered"`
+}
+
+// ModelReconciliation is one model's computed vs stats-cache totals. The
+// diff percentages are (computed - stats) / stats; nil when stats is zero.
+type ModelReconciliation struct {
+	Model         string   `json:"model"`
+	Tokens        int64    `json:"tokens"`
+	StatsTokens   int64    `json:"stats_tokens"`
+	TokensDiffPct *float64 `json:"tokens_diff_pct"`
+	CostUSD       float64  `json:"cost_usd"`
+	StatsCostUSD  float64  `json:"stats_cost_usd"`
+	CostDiffPct   *float64 `json:"cost_diff_pct"`
+}
+
 // StatsCache represents the pre-aggregated summary file.
 type StatsCache struct {
 	ModelUsage    map[string]StatsCacheModel `json:"modelUsage"`
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestUsageTotalsSub(t *testing.T) {
+	a := UsageTotals{InputTokens: 100, OutputTokens: 50, CacheCreationInputTokens: 10, CacheReadInputTokens: 1000, MessageCount: 4, CostUSD: 2.5, CacheReadCostUSD: 0.5}
+	b := UsageTotals{InputTokens: 40, OutputTokens: 80, CacheCreationInputTokens: 10, CacheReadInputTokens: 250, MessageCount: 6, CostUSD: 3, CacheReadCostUSD: 0.25}
+
+	want := UsageTotals{InputTokens: 60, OutputTokens: -30, CacheReadInputTokens: 750, MessageCount: -2, CostUSD: -0.5, CacheReadCostUSD: 0.25}
+	if got := a.Sub(b); got != want {
+		t.Errorf("a.Sub(b) = %+v, want %+v", got, want)
+	}
+	if got := a.Sub(a); got != (UsageTotals{}) {
+		t.Errorf("a.Sub(a) = %+v, want zero", got)
+	}
+	if got := a.Sub(UsageTotals{}); got != a {
+		t.Errorf("a.Sub(zero) = %+v, want %+v", got, a)
+	}
+}
+
+func TestUsageTotalsDiv(t *testing.T) {
+	a := UsageTotals{InputTokens: 100, OutputTokens: 7, CacheReadInputTokens: 1000, MessageCount: 5, CostUSD: 3, CacheUncachedCostUSD: 1.5}
+
+	tests := []struct {
+		n    float64
+		want UsageTotals
+	}{
+		{1, a},
+		{2, UsageTotals{InputTokens: 50, OutputTokens: 4, CacheReadInputTokens: 500, MessageCount: 3, CostUSD: 1.5, CacheUncachedCostUSD: 0.75}},
+		{4, UsageTotals{InputTokens: 25, OutputTokens: 2, CacheReadInputTokens: 250, MessageCount: 1, CostUSD: 0.75, CacheUncachedCostUSD: 0.375}},
+		{0.5, UsageTotals{InputTokens: 200, OutputTokens: 14, CacheReadInputTokens: 2000, MessageCount: 10, CostUSD: 6, CacheUncachedCostUSD: 3}},
+		{0, UsageTotals{}},
+	}
+	for _, tt := range tests {
+		if got := a.Div(tt.n); got != tt.want {
+			t.Errorf("Div(%v) = %+v, want %+v", tt.n, got, tt.want)
+		}
+	}
+	if got := (UsageTotals{}).Div(0); got != (UsageTotals{}) {
+		t.Errorf("zero.Div(0) = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "io"
+
+// ndjsonSession is a "type": "session" record of --format ndjson.
+type ndjsonSession struct {
+	Type string `json:"type"`
+	*SessionSummary
+}
+
+// ndjsonSummary is the final "type": "summary" record: the report without
+// the per-session list, which was already streamed.
+type ndjsonSummary struct {
+	Type string `json:"type"`
+	*AggregatedReport
+}
+
+// StreamNDJSON writes one compact JSON line per session while Aggregate is
+// still parsing, then a summary line with the grand totals and insights.
+// Sessions are not held in memory once written. style is applied to every
+// line as for --json. meta, if non-nil, is attached to the summary line.
+func StreamNDJSON(w io.Writer, files []FileInfo, opts AggregateOptions, meta *ReportMeta, minSeverity string, style jsonStyle) error {
+	ch := make(chan *SessionSummary, 64)
+	opts.SessionStream = ch
+
+	writeLine := func(v any) error {
+		data, err := encodeJSON(v, style)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		for sess := range ch {
+			// Keep draining after a write error so Aggregate never blocks.
+			if err == nil {
+				err = writeLine(ndjsonSession{Type: "session", SessionSummary: sess})
+			}
+		}
+		errc <- err
+	}()
+
+	report := Aggregate(files, opts)
+	close(ch)
+	if err := <-errc; err != nil {
+		return err
+	}
+
+	out := *report
+	out.Meta = meta
+	out.Insights, out.SuppressedInsights = FilterInsights(report.Insights, minSeverity)
+	return writeLine(ndjsonSummary{Type: "summary", AggregatedReport: &out})
+}
@@ -1,16 +1,19 @@
 package main
 
-import "math/rand"
+import (
+	"math/rand"
+	"time"
+)
 
 // CoachingTip is a single actionable nudge tied to the user's weakest clarity metric.
 type CoachingTip struct {
-	Metric    string // "correction_rate" | "clarification_rate" | "front_load_ratio"
-	SubMetric string // "scope" | "format" | "intent" — empty for non-correction tips
-	Level     string // "ok" | "warn"
-	Headline  string // short imperative phrase
-	Technique string // 2–3 sentence explanation
-	WeakEx    string // example of a weak prompt (newlines separate turns)
-	StrongEx  string // example of a strong prompt
+	Metric    string `json:"metric"`     // "correction_rate" | "clarification_rate" | "front_load_ratio"
+	SubMetric string `json:"sub_metric"` // "scope" | "format" | "intent" — empty for non-correction tips
+	Level     string `json:"level"`      // "ok" | "warn"
+	Headline  string `json:"headline"`   // short imperative phrase
+	Technique string `json:"technique"`  // 2–3 sentence explanation
+	WeakEx    string `json:"weak_ex"`    // example of a weak prompt (newlines separate turns)
+	StrongEx  string `json:"strong_ex"`  // example of a strong prompt
 }
 
 // tipBank maps "<metric>_<level>" to a slice of 2 tips that rotate weekly.
@@ -245,14 +248,26 @@ var tipBank = map[string][]CoachingTip{
 	},
 }
 
+// tipRand returns the random source used to pick tips. It is seeded from the
+// current ISO year and week so the same tips are shown all week, unless
+// cfg.ForceRegen asks for a fresh pick on every call.
+func tipRand(cfg ClarityConfig) *rand.Rand {
+	if cfg.ForceRegen {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	year, week := time.Now().ISOWeek()
+	return rand.New(rand.NewSource(int64(year*100 + week)))
+}
+
 // SelectCoachingTips returns one tip per detected correction type when
 // correction_rate is the weakest metric, or a single tip for other metrics.
-// Tip selection is randomised so it changes on each call.
+// Tip selection rotates weekly (see tipRand).
 // Returns nil when all metrics are good or data is insufficient.
-func SelectCoachingTips(r *ClarityReport) []*CoachingTip {
-	if r == nil || r.SessionCount < 2 {
+func SelectCoachingTips(r *ClarityReport, cfg ClarityConfig) []*CoachingTip {
+	if r == nil || r.ScoredSessionCount < 2 {
 		return nil
 	}
+	rng := tipRand(cfg)
 
 	o := r.Overall
 
@@ -302,7 +317,7 @@ func SelectCoachingTips(r *ClarityReport) []*CoachingTip {
 			}
 			key := "correction_" + ctype + "_" + worstLevel
 			if bucket, ok := tipBank[key]; ok && len(bucket) > 0 {
-				t := bucket[rand.Intn(len(bucket))]
+				t := bucket[rng.Intn(len(bucket))]
 				result = append(result, &t)
 			}
 		}
@@ -321,7 +336,7 @@ func SelectCoachingTips(r *ClarityReport) []*CoachingTip {
 			return nil
 		}
 	}
-	t := bucket[rand.Intn(len(bucket))]
+	t := bucket[rng.Intn(len(bucket))]
 	return []*CoachingTip{&t}
 }
 
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// writeFileAtomic writes path for --output: the content goes to a temp file
+// in the same directory, which is renamed over path only once write has
+// succeeded, so a crash or kill never leaves path truncated. With keep > 0
+// a dated copy (report-2006-01-02.json) is saved next to it and all but the
+// newest keep dated copies are removed.
+func writeFileAtomic(path string, keep int, write func(io.Writer) error) error {
+	if err := replaceFile(path, write); err != nil {
+		return err
+	}
+	if keep <= 0 {
+		return nil
+	}
+
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	dated := stem + "-" + time.Now().Format("2006-01-02") + ext
+	err := replaceFile(dated, func(w io.Writer) error {
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return pruneDatedCopies(stem, ext, keep)
+}
+
+// replaceFile atomically replaces path with what write produces.
+func replaceFile(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Harmless after a successful rename; cleans up on any failure.
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp makes the file 0600; keep the old file's mode, or use the
+	// usual 0644 for a new one.
+	mode := os.FileMode(0o644)
+	if st, err := os.Stat(path); err == nil {
+		mode = st.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	bw := bufio.NewWriter(tmp)
+	if err := write(bw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// pruneDatedCopies removes stem-YYYY-MM-DD.ext files beyond the newest keep.
+func pruneDatedCopies(stem, ext string, keep int) error {
+	matches, err := filepath.Glob(stem + "-[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]" + ext)
+	if err != nil {
+		return err
+	}
+	if len(matches) <= keep {
+		return nil
+	}
+	sort.Strings(matches) // dates sort lexically
+	for _, m := range matches[:len(matches)-keep] {
+		if err := os.Remove(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,18 +2,92 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
+	"time"
 )
 
+// MessageCounts tallies the non-assistant traffic of one session.
+type MessageCounts struct {
+	UserMessages    int
+	ToolResults     int
+	ToolResultBytes int64
+}
+
+// MessageTally collects per-session MessageCounts while ParseFile runs, so
+// user and tool_result records can be counted without a second read.
+// Records older than Since are ignored; a zero Since counts everything.
+type MessageTally struct {
+	Since    time.Time
+	Sessions map[string]*MessageCounts
+}
+
+func (t *MessageTally) count(rec MessageRecord) {
+	if rec.SessionID == "" || (!t.Since.IsZero() && rec.Timestamp.Before(t.Since)) {
+		return
+	}
+	c, ok := t.Sessions[rec.SessionID]
+	if !ok {
+		c = &MessageCounts{}
+		t.Sessions[rec.SessionID] = c
+	}
+	if isRealUserMessage(rec) {
+		c.UserMessages++
+		return
+	}
+	if n, ok := toolResultBytes(rec.Message.Content); ok {
+		c.ToolResults++
+		c.ToolResultBytes += n
+	}
+}
+
+// toolResultBytes sums the text size of all tool_result blocks in content.
+// The bool is false when content holds no tool_result block.
+func toolResultBytes(raw json.RawMessage) (int64, bool) {
+	if len(raw) == 0 || raw[0] != '[' {
+		return 0, false
+	}
+	var blocks []struct {
+		Type    string          `json:"type"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return 0, false
+	}
+	var total int64
+	found := false
+	for _, b := range blocks {
+		if b.Type != "tool_result" {
+			continue
+		}
+		found = true
+		if len(b.Content) > 0 && b.Content[0] == '"' {
+			var s string
+			if json.Unmarshal(b.Content, &s) == nil {
+				total += int64(len(s))
+			}
+			continue
+		}
+		total += int64(len(extractText(b.Content)))
+	}
+	return total, found
+}
+
 // ParseFile reads a JSONL file and returns all assistant-type records
 // that contain non-zero token usage. Malformed lines are silently skipped
 // and counted in the returned parseErrors count.
-// Records are deduplicated by UUID.
-func ParseFile(path string) (records []MessageRecord, parseErrors int) {
+// Records are deduplicated by UUID. When tally is non-nil, user and
+// tool_result records are counted into it instead of being discarded.
+//
+// A file still being written by a live session can end mid-record. A final
+// line that fails to parse and lacks its closing '}', or a line too long for
+// the scanner buffer, sets partialWrite instead of counting a parse error.
+func ParseFile(path string, tally *MessageTally) (records []MessageRecord, parseErrors int, partialWrite bool) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, 1
+		return nil, 1, false
 	}
 	defer f.Close()
 
@@ -22,21 +96,27 @@ func ParseFile(path string) (records []MessageRecord, parseErrors int) {
 	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
 
 	seen := make(map[string]bool)
+	lastTruncated := false // the latest non-empty line failed to parse and lacks a closing '}'
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		if len(line) == 0 {
 			continue
 		}
+		lastTruncated = false
 
 		var rec MessageRecord
 		if err := json.Unmarshal(line, &rec); err != nil {
 			parseErrors++
+			lastTruncated = !bytes.HasSuffix(bytes.TrimSpace(line), []byte("}"))
 			continue
 		}
 
 		// Only assistant records carry token usage
 		if rec.Type != "assistant" {
+			if tally != nil && rec.Type == "user" && !seenUUID(seen, rec.UUID) {
+				tally.count(rec)
+			}
 			continue
 		}
 
@@ -56,11 +136,32 @@ func ParseFile(path string) (records []MessageRecord, parseErrors int) {
 		records = append(records, rec)
 	}
 
+	if lastTruncated {
+		parseErrors--
+		partialWrite = true
+	}
 	if err := scanner.Err(); err != nil {
-		parseErrors++
+		if errors.Is(err, bufio.ErrTooLong) {
+			partialWrite = true
+		} else {
+			parseErrors++
+		}
 	}
 
-	return records, parseErrors
+	return records, parseErrors, partialWrite
+}
+
+// seenUUID reports whether uuid was already recorded in seen, recording it
+// if not. Empty UUIDs are never considered duplicates.
+func seenUUID(seen map[string]bool, uuid string) bool {
+	if uuid == "" {
+		return false
+	}
+	if seen[uuid] {
+		return true
+	}
+	seen[uuid] = true
+	return false
 }
 
 // ParseFileAllRecords reads a JSONL file and returns ALL records regardless of
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToolResultBytes(t *testing.T) {
+	big := strings.Repeat("x", 3*maxTextLen)
+	blocks := func(v ...any) json.RawMessage {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	result := func(content any) map[string]any {
+		return map[string]any{"type": "tool_result", "tool_use_id": "x", "content": content}
+	}
+	text := func(s string) map[string]any { return map[string]any{"type": "text", "text": s} }
+
+	tests := []struct {
+		name  string
+		raw   json.RawMessage
+		want  int64
+		found bool
+	}{
+		{"string content", blocks(result("hello")), 5, true},
+		{"block content", blocks(result([]any{text("hello"), text("world")})), 11, true},
+		{"large string content", blocks(result(big)), int64(len(big)), true},
+		{"large block content", blocks(result([]any{text(big)})), int64(len(big)), true},
+		{"several results", blocks(result("ab"), result([]any{text("cde")})), 5, true},
+		{"empty result", blocks(result("")), 0, true},
+		{"no tool result", blocks(text("hello")), 0, false},
+		{"plain prompt", json.RawMessage(`"hello"`), 0, false},
+		{"empty", nil, 0, false},
+	}
+	for _, tt := range tests {
+		n, found := toolResultBytes(tt.raw)
+		if n != tt.want || found != tt.found {
+			t.Errorf("%s: toolResultBytes = %d, %v; want %d, %v", tt.name, n, found, tt.want, tt.found)
+		}
+	}
+}
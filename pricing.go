@@ -9,6 +9,10 @@ type ModelPricing struct {
 	OutputPerMTok     float64
 	CacheWritePerMTok float64
 	CacheReadPerMTok  float64
+	// Notes describes billing conditions the flat rates above don't capture
+	// (e.g. how extended thinking is charged). Shown as a footnote in
+	// --model-pricing-table; empty for most families.
+	Notes string
 }
 
 // pricingTable maps model family prefixes to pricing.
@@ -21,6 +25,7 @@ var pricingTable = []ModelPricing{
 		OutputPerMTok:     75.00,
 		CacheWritePerMTok: 18.75,
 		CacheReadPerMTok:  1.50,
+		Notes:             "Output rate applies to thinking tokens",
 	},
 	{
 		Family:            "claude-sonnet-4",
@@ -28,6 +33,7 @@ var pricingTable = []ModelPricing{
 		OutputPerMTok:     15.00,
 		CacheWritePerMTok: 3.75,
 		CacheReadPerMTok:  0.30,
+		Notes:             "Output rate applies to thinking tokens",
 	},
 	{
 		Family:            "claude-haiku-4",
@@ -35,6 +41,7 @@ var pricingTable = []ModelPricing{
 		OutputPerMTok:     4.00,
 		CacheWritePerMTok: 1.00,
 		CacheReadPerMTok:  0.08,
+		Notes:             "Output rate applies to thinking tokens",
 	},
 	{
 		Family:            "claude-3-opus",
@@ -74,12 +81,14 @@ var pricingTable = []ModelPricing{
 }
 
 // LookupPricing returns the best-matching pricing for a model ID using
-// longest-prefix matching. Returns (zero, false) for unrecognized models.
+// case-insensitive longest-prefix matching. Returns (zero, false) for
+// unrecognized models.
 func LookupPricing(modelID string) (ModelPricing, bool) {
+	id := strings.ToLower(modelID)
 	var best ModelPricing
 	bestLen := -1
 	for _, p := range pricingTable {
-		if strings.HasPrefix(modelID, p.Family) && len(p.Family) > bestLen {
+		if strings.HasPrefix(id, strings.ToLower(p.Family)) && len(p.Family) > bestLen {
 			best = p
 			bestLen = len(p.Family)
 		}
@@ -87,16 +96,77 @@ func LookupPricing(modelID string) (ModelPricing, bool) {
 	return best, bestLen >= 0
 }
 
-// ComputeCost returns the USD cost for the given token usage and model ID.
-// Returns 0 for unrecognized model IDs.
-func ComputeCost(modelID string, u TokenUsage) float64 {
+// CostBreakdown splits a USD cost by token type.
+type CostBreakdown struct {
+	Input      float64
+	Output     float64
+	CacheWrite float64
+	CacheRead  float64
+	// CacheUncached is what the cache write and read tokens would have cost
+	// billed as plain input.
+	CacheUncached float64
+}
+
+// Total returns the billed cost: the sum of the four token-type components.
+func (c CostBreakdown) Total() float64 {
+	return c.Input + c.Output + c.CacheWrite + c.CacheRead
+}
+
+// ComputeCostBreakdown returns the per-token-type USD cost for the given
+// usage and model ID. Returns the zero value for unrecognized model IDs.
+func ComputeCostBreakdown(modelID string, u TokenUsage) CostBreakdown {
 	p, ok := LookupPricing(modelID)
 	if !ok {
-		return 0
+		return CostBreakdown{}
 	}
 	const mtok = 1_000_000.0
-	return float64(u.InputTokens)/mtok*p.InputPerMTok +
-		float64(u.OutputTokens)/mtok*p.OutputPerMTok +
-		float64(u.CacheCreationInputTokens)/mtok*p.CacheWritePerMTok +
-		float64(u.CacheReadInputTokens)/mtok*p.CacheReadPerMTok
+	return CostBreakdown{
+		Input:         float64(u.InputTokens) / mtok * p.InputPerMTok,
+		Output:        float64(u.OutputTokens) / mtok * p.OutputPerMTok,
+		CacheWrite:    float64(u.CacheCreationInputTokens) / mtok * p.CacheWritePerMTok,
+		CacheRead:     float64(u.CacheReadInputTokens) / mtok * p.CacheReadPerMTok,
+		CacheUncached: float64(u.CacheCreationInputTokens+u.CacheReadInputTokens) / mtok * p.InputPerMTok,
+	}
+}
+
+// ComputeCost returns the USD cost for the given token usage and model ID.
+// Returns 0 for unrecognized model IDs.
+func ComputeCost(modelID string, u TokenUsage) float64 {
+	return ComputeCostBreakdown(modelID, u).Total()
+}
+
+// DisplayModelName shortens a model ID like "claude-sonnet-4-5-20250929" to
+// "Sonnet 4.5" (or "claude-3-5-haiku-20241022" to "Haiku 3.5"). IDs that don't
+// follow the claude-<family>-<version> pattern are returned unchanged.
+func DisplayModelName(modelID string) string {
+	parts := strings.Split(strings.ToLower(modelID), "-")
+	if len(parts) < 2 || parts[0] != "claude" {
+		return modelID
+	}
+	var family string
+	var version []string
+	for _, part := range parts[1:] {
+		switch {
+		case part == "opus" || part == "sonnet" || part == "haiku":
+			family = strings.ToUpper(part[:1]) + part[1:]
+		case len(part) <= 2 && isDigits(part):
+			version = append(version, part)
+		}
+	}
+	if family == "" || len(version) == 0 {
+		return modelID
+	}
+	return family + " " + strings.Join(version, ".")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
 }
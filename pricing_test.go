@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLookupPricing(t *testing.T) {
+	tests := []struct {
+		model  string
+		family string
+		ok     bool
+	}{
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4", true},
+		{"Claude-Sonnet-4", "claude-sonnet-4", true},
+		{"CLAUDE-OPUS-4-1-20250805", "claude-opus-4", true},
+		{"claude-3-5-Sonnet-20241022", "claude-3-5-sonnet", true},
+		{"Claude-3-Haiku-20240307", "claude-3-haiku", true},
+		{"claude-3-5-haiku-latest", "claude-3-5-haiku", true},
+		{"gpt-4o", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		p, ok := LookupPricing(tt.model)
+		if ok != tt.ok || p.Family != tt.family {
+			t.Errorf("LookupPricing(%q) = (%q, %v), want (%q, %v)", tt.model, p.Family, ok, tt.family, tt.ok)
+		}
+	}
+}
+
+func TestComputeCostBreakdownMixedCase(t *testing.T) {
+	u := TokenUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+	lower := ComputeCostBreakdown("claude-sonnet-4-5", u)
+	mixed := ComputeCostBreakdown("Claude-Sonnet-4-5", u)
+	if lower != mixed {
+		t.Errorf("mixed-case cost = %+v, want %+v", mixed, lower)
+	}
+	if math.Abs(lower.Total()-18) > 1e-9 {
+		t.Errorf("Total() = %v, want 18", lower.Total())
+	}
+	if got := ComputeCostBreakdown("unknown-model", u); got != (CostBreakdown{}) {
+		t.Errorf("unknown model cost = %+v, want zero", got)
+	}
+}
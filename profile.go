@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"time"
+)
+
+// PhaseTimings splits a run's wall time by pipeline phase. Aggregate fills
+// Parse (reading and decoding files), Aggregate (the rest of Aggregate) and
+// Clarity; main adds Discover and Render.
+type PhaseTimings struct {
+	Discover  time.Duration
+	Parse     time.Duration
+	Aggregate time.Duration
+	Clarity   time.Duration
+	Render    time.Duration
+
+	renderStart time.Time // set by main once output begins
+}
+
+type phaseTiming struct {
+	name string
+	d    time.Duration
+}
+
+// phases lists the timings in pipeline order.
+func (t PhaseTimings) phases() []phaseTiming {
+	return []phaseTiming{
+		{"discover", t.Discover},
+		{"parse", t.Parse},
+		{"aggregate", t.Aggregate},
+		{"clarity", t.Clarity},
+		{"render", t.Render},
+	}
+}
+
+// Millis returns the measured phases in milliseconds, keyed by name; phases
+// that did not run are left out.
+func (t PhaseTimings) Millis() map[string]float64 {
+	m := make(map[string]float64)
+	for _, ph := range t.phases() {
+		if ph.d > 0 {
+			m[ph.name] = float64(ph.d.Microseconds()) / 1000
+		}
+	}
+	return m
+}
+
+// Print writes the --profile phase breakdown with each phase's share.
+func (t PhaseTimings) Print(w io.Writer) {
+	var total time.Duration
+	for _, ph := range t.phases() {
+		total += ph.d
+	}
+	fmt.Fprintln(w, "Phase timings:")
+	for _, ph := range t.phases() {
+		share := 0.0
+		if total > 0 {
+			share = float64(ph.d) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "  %-10s %10s  %5.1f%%\n", ph.name, ph.d.Round(time.Microsecond), share)
+	}
+	fmt.Fprintf(w, "  %-10s %10s\n", "total", total.Round(time.Microsecond))
+}
+
+// runTimings collects the phase timings under --profile; nil otherwise.
+var runTimings *PhaseTimings
+
+// stopProfile finishes any profile started by startProfile. It is a no-op
+// until a profile is running, so exit paths can always call it.
+var stopProfile = func() {}
+
+// startProfile begins a diagnostic profile for --profile. mode is "cpu",
+// "mem" or "trace", with output in ./token-analyzer-<mode>.pprof (or .out
+// for traces), or a directory that gets both cpu.pprof and heap.pprof. Any
+// of them also prints the phase timings when the profile stops. An empty
+// mode does nothing.
+func startProfile(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	runTimings = &PhaseTimings{}
+	if err := startPprof(mode); err != nil {
+		return err
+	}
+	stop := stopProfile
+	stopProfile = func() {
+		if !runTimings.renderStart.IsZero() {
+			runTimings.Render = time.Since(runTimings.renderStart)
+		}
+		stop()
+		runTimings.Print(os.Stderr)
+		stopProfile = func() {}
+	}
+	return nil
+}
+
+func startPprof(mode string) error {
+	switch mode {
+	case "cpu":
+		f, err := os.Create("token-analyzer-cpu.pprof")
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		stopProfile = func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		}
+	case "mem":
+		stopProfile = func() { writeHeapProfile("token-analyzer-mem.pprof") }
+	case "trace":
+		f, err := os.Create("token-analyzer-trace.out")
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		stopProfile = func() {
+			trace.Stop()
+			f.Close()
+		}
+	default:
+		if err := os.MkdirAll(mode, 0o755); err != nil {
+			return fmt.Errorf("--profile: %w", err)
+		}
+		f, err := os.Create(filepath.Join(mode, "cpu.pprof"))
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		stopProfile = func() {
+			pprof.StopCPUProfile()
+			f.Close()
+			writeHeapProfile(filepath.Join(mode, "heap.pprof"))
+			fmt.Fprintf(os.Stderr, "Wrote %s and %s\n", f.Name(), filepath.Join(mode, "heap.pprof"))
+		}
+	}
+	return nil
+}
+
+// writeHeapProfile writes the current heap profile to path, reporting
+// failures on stderr since it runs on the way out.
+func writeHeapProfile(path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing heap profile: %v\n", err)
+		return
+	}
+	defer f.Close()
+	runtime.GC() // up-to-date heap statistics
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing heap profile: %v\n", err)
+	}
+}
+
+// exit stops any running profile and then exits with code.
+func exit(code int) {
+	stopProfile()
+	os.Exit(code)
+}
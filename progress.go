@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressLine draws a single in-place status line ("Parsing 214/890 files,
+// 1.2 GB read…") while Aggregate runs. Redraws are throttled so a directory
+// of thousands of small files doesn't spend its time writing to the terminal.
+type progressLine struct {
+	w        io.Writer
+	lastDraw time.Time
+	drawn    bool
+}
+
+// Update is an AggregateOptions.Progress callback.
+func (pl *progressLine) Update(done, total int, bytesRead int64) {
+	if done < total && time.Since(pl.lastDraw) < 100*time.Millisecond {
+		return
+	}
+	pl.lastDraw = time.Now()
+	pl.drawn = true
+	fmt.Fprintf(pl.w, "\r\033[KParsing %d/%d files, %s read…", done, total, fmtBytes(bytesRead))
+}
+
+// Clear erases the line so the report starts on a clean row.
+func (pl *progressLine) Clear() {
+	if pl.drawn {
+		fmt.Fprint(pl.w, "\r\033[K")
+		pl.drawn = false
+	}
+}
+
+// fmtBytes formats a byte count as "512 B", "3.4 MB", "1.2 GB".
+func fmtBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
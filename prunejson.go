@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// jsonStyle selects how report JSON is post-processed after marshaling.
+type jsonStyle struct {
+	Legacy bool // --legacy-json: old Go field names as keys; implies Full
+	Full   bool // --json-full: keep null, empty and unset fields
+}
+
+// unsetKeys are report fields whose zero value means "not set" rather than
+// a real zero, so they are pruned like null.
+var unsetKeys = map[string]string{
+	"filter_days":    "0",
+	"filter_project": `""`,
+}
+
+// encodeJSON marshals v compactly in the given style.
+func encodeJSON(v any, style jsonStyle) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if !style.Full && !style.Legacy {
+		if data, err = pruneJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	if style.Legacy {
+		if data, err = legacyJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// pruneJSON drops object fields holding absent data — null, [], {} or an
+// unset filter — keeping key order. Keys of Go maps (model IDs, project
+// names) are data, so map entries are never dropped, though their values
+// are pruned in turn.
+func pruneJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := pruneValue(dec, &buf, false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pruneValue copies one JSON value from dec to buf, pruning inside objects
+// unless the object is a Go map (inMap).
+func pruneValue(dec *json.Decoder, buf *bytes.Buffer, inMap bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			buf.WriteByte('{')
+			var val bytes.Buffer
+			n := 0
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := kt.(string)
+				val.Reset()
+				if err := pruneValue(dec, &val, !inMap && legacyMapKeys[key]); err != nil {
+					return err
+				}
+				if !inMap && isAbsentJSON(key, val.Bytes()) {
+					continue
+				}
+				if n > 0 {
+					buf.WriteByte(',')
+				}
+				writeJSONString(buf, key)
+				buf.WriteByte(':')
+				buf.Write(val.Bytes())
+				n++
+			}
+			buf.WriteByte('}')
+		case '[':
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := pruneValue(dec, buf, false); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		}
+		// Consume the closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	case string:
+		writeJSONString(buf, t)
+	case json.Number:
+		buf.WriteString(t.String())
+	case bool:
+		if t {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func isAbsentJSON(key string, val []byte) bool {
+	switch string(val) {
+	case "null", "[]", "{}":
+		return true
+	}
+	zero, ok := unsetKeys[key]
+	return ok && string(val) == zero
+}
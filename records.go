@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// UsageRecord is one assistant message as counted by Aggregate, after the
+// date and project filters. It backs --include-records and --records-out.
+type UsageRecord struct {
+	UUID                     string    `json:"uuid"`
+	SessionID                string    `json:"session_id"`
+	Project                  string    `json:"project"`
+	Model                    string    `json:"model"`
+	Timestamp                time.Time `json:"timestamp"`
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens"`
+	CostUSD                  float64   `json:"cost_usd"`
+}
+
+// recordWriter encodes UsageRecords as NDJSON while Aggregate runs, so the
+// records are never held in memory. After a write error it keeps the error
+// and drops the remaining records.
+type recordWriter struct {
+	w      *bufio.Writer
+	legacy bool // rename keys as for --legacy-json
+	count  int
+	err    error
+}
+
+func newRecordWriter(w io.Writer, legacy bool) *recordWriter {
+	return &recordWriter{w: bufio.NewWriter(w), legacy: legacy}
+}
+
+// Write is the AggregateOptions.RecordSink.
+func (rw *recordWriter) Write(r *UsageRecord) {
+	if rw.err != nil {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err == nil && rw.legacy {
+		data, err = legacyJSON(data)
+	}
+	if err == nil {
+		_, err = rw.w.Write(append(data, '\n'))
+	}
+	if err != nil {
+		rw.err = err
+		return
+	}
+	rw.count++
+}
+
+// Flush writes any buffered records and returns the first error seen.
+func (rw *recordWriter) Flush() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	return rw.w.Flush()
+}
+
+// writeJSONWithRecords writes v like writeJSON, with an extra trailing
+// "records" array copied line by line from the NDJSON in records.
+func writeJSONWithRecords(w io.Writer, v any, records io.Reader, newline bool, style jsonStyle) error {
+	data, err := indentJSON(v, style)
+	if err != nil {
+		return err
+	}
+	// Reopen the object: indented output always ends in "\n}".
+	bw := bufio.NewWriter(w)
+	bw.Write(data[:len(data)-2])
+	bw.WriteString(",\n  \"records\": [")
+
+	sc := bufio.NewScanner(records)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for sc.Scan() {
+		if n > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString("\n    ")
+		bw.Write(sc.Bytes())
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if n > 0 {
+		bw.WriteString("\n  ")
+	}
+	bw.WriteString("]\n}")
+	if newline {
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
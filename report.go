@@ -6,8 +6,10 @@ import (
 	"math"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -25,19 +27,59 @@ const (
 	colorGray    = "\033[90m"
 )
 
-// isTerminal returns true if w is a real TTY.
+// terminalWidth returns the width from $COLUMNS, or 0 if it is unknown.
+func terminalWidth() int {
+	n, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+// isTerminal returns true if stdout is a real TTY.
 func isTerminal() bool {
-	fi, err := os.Stdout.Stat()
+	return isTTY(os.Stdout)
+}
+
+// isTTY reports whether f is a character device (an interactive terminal).
+func isTTY(f *os.File) bool {
+	fi, err := f.Stat()
 	if err != nil {
 		return false
 	}
 	return (fi.Mode() & os.ModeCharDevice) != 0
 }
 
+// PrintOptions controls presentation choices for the terminal report.
+type PrintOptions struct {
+	TopModels int // max rows in the model table; 0 = all
+	Width     int // terminal width in columns; 0 = unknown (assume wide)
+
+	TrendMetric string // "tokens" (default) or "cost": what trend bars are scaled by
+
+	MonthlyModels bool // print the month × model-family cost matrix
+
+	MinSeverity string // "info" (default) or "warn": lowest insight severity shown
+
+	ShowSessions       bool // list each project's top sessions under its row
+	SessionsPerProject int  // how many sessions ShowSessions lists; 0 = all
+
+	ExpandSessions bool // show each TOP SESSIONS row's time range and top models
+
+	RawModelNames bool // show full model IDs instead of "Sonnet 4.5"-style names
+
+	SidechainReport bool // print the SIDECHAIN BREAKDOWN section
+
+	Abbrev bool // show token counts as 1.2K / 3.4M / 1.1B and costs over $1,000 as $1.2K instead of exact figures
+
+	SortBy string // PROJECTS order: "tokens" (default), "cost" or "recency"
+}
+
 // Printer wraps output and applies colors only when useColors is true.
 type Printer struct {
 	w         io.Writer
 	useColors bool
+	opts      PrintOptions
 }
 
 func (p *Printer) color(code, s string) string {
@@ -56,6 +98,14 @@ func (p *Printer) cyan(s string) string    { return p.color(colorCyan, s) }
 func (p *Printer) magenta(s string) string { return p.color(colorMagenta, s) }
 func (p *Printer) gray(s string) string    { return p.color(colorGray, s) }
 
+// modelName returns the display form of a model ID per the print options.
+func (p *Printer) modelName(id string) string {
+	if p.opts.RawModelNames {
+		return id
+	}
+	return DisplayModelName(id)
+}
+
 func (p *Printer) printf(format string, args ...any) {
 	fmt.Fprintf(p.w, format, args...)
 }
@@ -67,20 +117,42 @@ func (p *Printer) println(s string) {
 // ---- Formatting helpers ----
 
 func fmtTokens(n int64) string {
-	if n == 0 {
-		return "0"
+	return fmtInt(n)
+}
+
+// fmtInt formats n with comma thousands separators: 2400 → "2,400".
+func fmtInt(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
 	}
-	// Insert commas
-	s := fmt.Sprintf("%d", n)
 	result := make([]byte, 0, len(s)+len(s)/3)
-	for i, c := range s {
-		pos := len(s) - i
-		if i > 0 && pos%3 == 0 {
+	for i := 0; i < len(s); i++ {
+		if i > 0 && (len(s)-i)%3 == 0 {
 			result = append(result, ',')
 		}
-		result = append(result, byte(c))
+		result = append(result, s[i])
 	}
-	return string(result)
+	return sign + string(result)
+}
+
+// tokens formats a token count for display: abbreviated with --abbrev,
+// comma-separated otherwise.
+func (p *Printer) tokens(n int64) string {
+	if p.opts.Abbrev {
+		return fmtTokensInt(n)
+	}
+	return fmtTokens(n)
+}
+
+// cost formats a USD amount for display: compacted above $1,000 with
+// --abbrev, exact to the cent otherwise.
+func (p *Printer) cost(f float64) string {
+	if p.opts.Abbrev {
+		return fmtCostCompact(f)
+	}
+	return fmtCost(f)
 }
 
 func fmtPct(f float64) string {
@@ -94,6 +166,21 @@ func fmtCost(f float64) string {
 	return fmt.Sprintf("$%.2f", f)
 }
 
+// fmtCostCompact formats costs of $1,000 and more as $1.2K / $3.4M and
+// smaller ones like fmtCost.
+func fmtCostCompact(f float64) string {
+	if math.Abs(f) < 1_000 {
+		return fmtCost(f)
+	}
+	if f < 0 {
+		return "-" + fmtCostCompact(-f)
+	}
+	if v := math.Floor(f/1_000*10+0.5) / 10; v < 1_000 {
+		return fmt.Sprintf("$%.1fK", v)
+	}
+	return fmt.Sprintf("$%.1fM", math.Floor(f/1_000_000*10+0.5)/10)
+}
+
 func fmtTime(t time.Time) string {
 	if t.IsZero() {
 		return "—"
@@ -108,12 +195,103 @@ func fmtDate(t time.Time) string {
 	return t.Local().Format("Jan 02, 2006")
 }
 
+// wideRanges lists the East Asian wide/fullwidth and emoji blocks that take
+// two terminal cells. It is a compact approximation of Unicode's
+// EastAsianWidth table, enough for project names and branches.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo initials
+	{0x2E80, 0x303E},   // CJK radicals, punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, CJK symbols
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x1F300, 0x1F64F}, // pictographs, emoticons
+	{0x1F680, 0x1F6FF}, // transport and map symbols
+	{0x1F900, 0x1F9FF}, // supplemental pictographs
+	{0x20000, 0x3FFFD}, // CJK extensions B onwards
+}
+
+// runeWidth returns the number of terminal cells r occupies: 0 for
+// combining marks and zero-width format characters, 2 for wide characters,
+// otherwise 1.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, rg := range wideRanges {
+		if r < rg[0] {
+			break
+		}
+		if r <= rg[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth returns the number of terminal cells s occupies.
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// truncate shortens s to at most n terminal cells, ending in "…" when cut.
 func truncate(s string, n int) string {
-	if utf8.RuneCountInString(s) <= n {
+	if displayWidth(s) <= n {
 		return s
 	}
-	runes := []rune(s)
-	return string(runes[:n-1]) + "…"
+	if n <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	w := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if w+rw > n-1 {
+			break
+		}
+		b.WriteRune(r)
+		w += rw
+	}
+	return b.String() + "…"
+}
+
+// padCell truncates s to n terminal cells and right-pads it with spaces to
+// exactly n. Use it with %s in place of %-Ns, which pads by rune count and
+// misaligns wide characters.
+func padCell(s string, n int) string {
+	s = truncate(s, n)
+	return s + strings.Repeat(" ", n-displayWidth(s))
+}
+
+// padCellLeft is padCell for right-aligned columns, in place of %Ns.
+func padCellLeft(s string, n int) string {
+	s = truncate(s, n)
+	return strings.Repeat(" ", n-displayWidth(s)) + s
+}
+
+// fmtDuration renders d as a short human string like "2h 14m" or "45s".
+func fmtDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "—"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
 }
 
 func fmtHourOfDay(h int) string {
@@ -165,6 +343,24 @@ func sparkline(values []int64) string {
 	return sb.String()
 }
 
+// SparklineNormalized renders values as a sparkline at most width cells wide.
+// Longer inputs are split into width contiguous buckets, each drawn as the
+// sum of its values; a bucket summing to zero still shows as an empty cell.
+// Inputs that already fit are rendered one cell per value, as by sparkline.
+func SparklineNormalized(values []int64, width int) string {
+	if width <= 0 || len(values) <= width {
+		return sparkline(values)
+	}
+	buckets := make([]int64, width)
+	for i := range buckets {
+		lo, hi := i*len(values)/width, (i+1)*len(values)/width
+		for _, v := range values[lo:hi] {
+			buckets[i] += v
+		}
+	}
+	return sparkline(buckets)
+}
+
 // ---- Cache efficiency bar ----
 
 func cacheBar(pct float64, width int) string {
@@ -185,13 +381,13 @@ func sectionHeader(p *Printer, title string) {
 
 // ---- Main report printer ----
 
-func PrintReport(w io.Writer, r *AggregatedReport, useColors bool) {
-	p := &Printer{w: w, useColors: useColors}
+func PrintReport(w io.Writer, r *AggregatedReport, useColors bool, opts PrintOptions) {
+	p := &Printer{w: w, useColors: useColors, opts: opts}
 
 	// Header
 	p.println(p.bold("╔══════════════════════════════════════════════════════╗"))
 	p.println(p.bold("║          CLAUDE CODE TOKEN ANALYZER                  ║"))
-	period := periodStr(r)
+	period := r.DateRange()
 	padded := fmt.Sprintf("%-52s", "║  Period: "+period)
 	p.println(p.bold(padded + "║"))
 	p.println(p.bold("╚══════════════════════════════════════════════════════╝"))
@@ -199,22 +395,42 @@ func PrintReport(w io.Writer, r *AggregatedReport, useColors bool) {
 
 	printOverallSummary(p, r)
 	printModelBreakdown(p, r)
+	if p.opts.MonthlyModels {
+		printMonthlyModels(p, r)
+	}
 	printProjects(p, r)
 	printSessions(p, r)
+	if p.opts.SidechainReport {
+		printSidechains(p, r)
+	}
 	printDailyTrend(p, r)
+	printCalendar(p, r)
 	printInsights(p, r)
 	printClaritySection(p, r)
 	printCoachingSection(p, r)
 }
 
-func periodStr(r *AggregatedReport) string {
-	if r.FilterDays > 0 {
-		return fmt.Sprintf("Last %d days", r.FilterDays)
-	}
-	if r.DateFrom.IsZero() {
-		return "No data"
-	}
-	return fmtDate(r.DateFrom) + " – " + fmtDate(r.DateTo)
+// OnelineSummary formats t as a single uncolored, unpadded line for shell
+// prompts and status bars. The field order is stable:
+//
+//	<label>: <tokens> tok / <cost> / cache <efficiency>%
+func OnelineSummary(label string, t UsageTotals) string {
+	return fmt.Sprintf("%s: %s tok / %s / cache %.0f%%",
+		label, fmtTokensInt(t.TotalTokens()), fmtCost(t.CostUSD), t.CacheEfficiency()*100)
+}
+
+// SummaryLine formats the --print-summary-line trailer: space-separated
+// key=value pairs for scripts. The keys, their order and their units are a
+// stable interface; add new keys at the end rather than changing these.
+//
+//	tokens        total tokens (integer)
+//	cost_usd      estimated cost in USD, two decimals
+//	sessions      number of sessions
+//	cache_eff     cache efficiency in [0,1], two decimals
+//	parse_errors  JSONL lines that failed to parse
+func SummaryLine(r *AggregatedReport) string {
+	return fmt.Sprintf("tokens=%d cost_usd=%.2f sessions=%d cache_eff=%.2f parse_errors=%d",
+		r.Grand.TotalTokens(), r.Grand.CostUSD, len(r.Sessions), r.Grand.CacheEfficiency(), r.ParseErrors)
 }
 
 func printOverallSummary(p *Printer, r *AggregatedReport) {
@@ -230,15 +446,26 @@ func printOverallSummary(p *Printer, r *AggregatedReport) {
 	}
 
 	p.printf("  %-28s  %14s  %8s\n",
-		"Input tokens", fmtTokens(r.Grand.InputTokens), p.gray("("+pctOf(r.Grand.InputTokens)+")"))
+		"Input tokens", p.tokens(r.Grand.InputTokens), p.gray("("+pctOf(r.Grand.InputTokens)+")"))
 	p.printf("  %-28s  %14s  %8s\n",
-		"Output tokens", fmtTokens(r.Grand.OutputTokens), p.gray("("+pctOf(r.Grand.OutputTokens)+")"))
+		"Output tokens", p.tokens(r.Grand.OutputTokens), p.gray("("+pctOf(r.Grand.OutputTokens)+")"))
 	p.printf("  %-28s  %14s  %8s\n",
-		"Cache writes", fmtTokens(r.Grand.CacheCreationInputTokens), p.gray("("+pctOf(r.Grand.CacheCreationInputTokens)+")"))
+		"Cache writes", p.tokens(r.Grand.CacheCreationInputTokens), p.gray("("+pctOf(r.Grand.CacheCreationInputTokens)+")"))
 	p.printf("  %-28s  %14s  %8s\n",
-		"Cache reads", fmtTokens(r.Grand.CacheReadInputTokens), p.gray("("+pctOf(r.Grand.CacheReadInputTokens)+")"))
+		"Cache reads", p.tokens(r.Grand.CacheReadInputTokens), p.gray("("+pctOf(r.Grand.CacheReadInputTokens)+")"))
 	p.println("  " + strings.Repeat("─", 54))
-	p.printf("  %-28s  %14s\n", p.bold("Total tokens"), p.bold(fmtTokens(total)))
+	var exact string
+	if p.opts.Abbrev {
+		exact = "  " + p.gray("("+fmtTokens(total)+")")
+	}
+	p.printf("  %-28s  %14s%s%s\n", p.bold("Total tokens"), p.bold(p.tokens(total)), exact, p.previousDelta(r,
+		func(t UsageTotals) float64 { return float64(t.TotalTokens()) }, false))
+	if r.NoSubagents {
+		p.println(p.gray("  Subagent data excluded (--no-subagents)"))
+	} else if mainT, subT := r.GrandByType["main"], r.GrandByType["subagent"]; mainT != nil && subT != nil {
+		p.println(p.gray(fmt.Sprintf("  Main session tokens: %s · Subagent tokens: %s",
+			p.tokens(mainT.TotalTokens()), p.tokens(subT.TotalTokens()))))
+	}
 	p.println("")
 
 	eff := r.Grand.CacheEfficiency()
@@ -255,8 +482,19 @@ func printOverallSummary(p *Printer, r *AggregatedReport) {
 		effStr += "  " + p.red("low")
 		label = p.red(label)
 	}
-	p.printf("  %-28s  %s\n", label, effStr)
-	p.printf("  %-28s  %s\n", "Estimated cost", p.bold(fmtCost(r.Grand.CostUSD)))
+	p.printf("  %-28s  %s%s\n", label, effStr, p.previousDelta(r, UsageTotals.CacheEfficiency, true))
+	p.printf("  %-28s  %s%s\n", "Estimated cost", p.bold(p.cost(r.Grand.CostUSD)), p.previousDelta(r,
+		func(t UsageTotals) float64 { return t.CostUSD }, false))
+	if g := r.Grand; g.CacheCreationInputTokens+g.CacheReadInputTokens > 0 {
+		p.printf("  %-28s  writes %s · reads %s  %s\n", "Cache spend",
+			p.cost(g.CacheWriteCostUSD), p.cost(g.CacheReadCostUSD),
+			p.gray("(would have been "+p.cost(g.CacheUncachedCostUSD)+" uncached)"))
+	}
+	if r.CacheSavingsUSD > 0 {
+		p.printf("  %s\n", p.green("You saved "+p.cost(r.CacheSavingsUSD)+" via caching this period."))
+	}
+	p.printf("  %-28s  %s  %s\n", "API requests", fmtTokens(r.APIRequests),
+		p.gray(fmt.Sprintf("(%s · %s output tokens per request)", p.cost(r.AvgCostPerRequest), p.tokens(int64(math.Round(r.AvgOutputPerRequest))))))
 	p.println("")
 
 	// Session counts
@@ -269,14 +507,58 @@ func printOverallSummary(p *Printer, r *AggregatedReport) {
 	}
 	models := len(r.ModelSummaries)
 	p.printf("  %-28s  %d  %s\n", "Sessions", sessionCount, p.gray(fmt.Sprintf("(%d with subagents)", subCount)))
-	p.printf("  %-28s  %d  %s\n", "Models used", models, p.gray(modelList(r.ModelSummaries)))
+	if sessionCount > 0 {
+		pc := r.Percentiles
+		p.printf("  %-28s  P50 %s · P90 %s · P99 %s\n", "Session size",
+			fmtTokensInt(pc.P50), fmtTokensInt(pc.P90), fmtTokensInt(pc.P99))
+	}
+	p.printf("  %-28s  %d  %s\n", "Models used", models, p.gray(modelList(p, r.ModelSummaries)))
+	p.printf("  %-28s  %d session, %d subagent\n", "Files analyzed", r.MainFileCount, r.SubagentFileCount)
+	if !r.FirstUseDate.IsZero() {
+		unit := "days"
+		if r.DaysSinceFirstUse == 1 {
+			unit = "day"
+		}
+		p.printf("  %-28s  %d %s  %s\n", "Using Claude Code for", r.DaysSinceFirstUse, unit,
+			p.gray("(since "+fmtDate(r.FirstUseDate)+")"))
+	}
 	p.println("")
 }
 
-func modelList(m map[string]*UsageTotals) string {
+// previousDelta renders "  ↑ 12% vs prev" comparing metric on r.Grand against the
+// preceding window. Green marks an improvement per higherIsBetter. Returns ""
+// when there is no previous window or it has no data.
+func (p *Printer) previousDelta(r *AggregatedReport, metric func(UsageTotals) float64, higherIsBetter bool) string {
+	if r.Previous == nil {
+		return ""
+	}
+	prev, cur := metric(*r.Previous), metric(r.Grand)
+	if prev == 0 {
+		return ""
+	}
+	change := (cur - prev) / prev
+	arrow := "→"
+	switch {
+	case change > 0.005:
+		arrow = "↑"
+	case change < -0.005:
+		arrow = "↓"
+	}
+	s := fmt.Sprintf("  %s %.0f%% vs prev", arrow, math.Abs(change)*100)
+	switch {
+	case arrow == "→":
+		return p.gray(s)
+	case (change > 0) == higherIsBetter:
+		return p.green(s)
+	default:
+		return p.red(s)
+	}
+}
+
+func modelList(p *Printer, m map[string]*UsageTotals) string {
 	var names []string
 	for k := range m {
-		names = append(names, k)
+		names = append(names, p.modelName(k))
 	}
 	sort.Strings(names)
 	if len(names) <= 3 {
@@ -304,21 +586,117 @@ func printModelBreakdown(p *Printer, r *AggregatedReport) {
 		return entries[i].totals.TotalTokens() > entries[j].totals.TotalTokens()
 	})
 
-	header := fmt.Sprintf("  %-36s  %10s  %10s  %10s  %10s  %8s",
-		"Model", "Input", "Output", "Cache Wr", "Cache Rd", "Cost")
+	header := fmt.Sprintf("  %-36s  %10s  %10s  %10s  %10s  %8s  %10s  %10s",
+		"Model", "Input", "Output", "Cache Wr", "Cache Rd", "Cost", "First seen", "Last seen")
 	p.println(p.dim(header))
-	p.println("  " + strings.Repeat("─", 92))
+	p.println("  " + strings.Repeat("─", 116))
+
+	seen := func(t time.Time) string {
+		if t.IsZero() {
+			return "—"
+		}
+		return t.Local().Format("2006-01-02")
+	}
+
+	// Fold models beyond the limit into a single "(other models)" row
+	var hidden []mEntry
+	if limit := p.opts.TopModels; limit > 0 && len(entries) > limit {
+		hidden = entries[limit:]
+		entries = entries[:limit]
+		other := &UsageTotals{}
+		for _, e := range hidden {
+			other.Merge(*e.totals)
+		}
+		entries = append(entries, mEntry{"(other models)", other})
+	}
 
 	for _, e := range entries {
-		p.printf("  %-36s  %10s  %10s  %10s  %10s  %8s\n",
-			truncate(e.name, 36),
-			fmtTokens(e.totals.InputTokens),
-			fmtTokens(e.totals.OutputTokens),
-			fmtTokens(e.totals.CacheCreationInputTokens),
-			fmtTokens(e.totals.CacheReadInputTokens),
-			fmtCost(e.totals.CostUSD),
+		first, last := r.ModelFirstSeen[e.name], r.ModelLastSeen[e.name]
+		if e.name == "(other models)" {
+			for _, h := range hidden {
+				if f := r.ModelFirstSeen[h.name]; !f.IsZero() && (first.IsZero() || f.Before(first)) {
+					first = f
+				}
+				if l := r.ModelLastSeen[h.name]; l.After(last) {
+					last = l
+				}
+			}
+		}
+		p.printf("  %s  %10s  %10s  %10s  %10s  %8s  %10s  %10s\n",
+			padCell(p.modelName(e.name), 36),
+			p.tokens(e.totals.InputTokens),
+			p.tokens(e.totals.OutputTokens),
+			p.tokens(e.totals.CacheCreationInputTokens),
+			p.tokens(e.totals.CacheReadInputTokens),
+			p.cost(e.totals.CostUSD),
+			seen(first),
+			seen(last),
 		)
 	}
+	if len(hidden) > 0 {
+		p.println(p.gray(fmt.Sprintf("  … and %d more models", len(hidden))))
+	}
+	p.println("")
+}
+
+// printMonthlyModels prints cost per calendar month (rows) and model family
+// (columns). Models missing from the pricing table are grouped by raw ID.
+func printMonthlyModels(p *Printer, r *AggregatedReport) {
+	if len(r.MonthlyModelBreakdown) == 0 {
+		return
+	}
+	sectionHeader(p, "MONTHLY COST BY MODEL")
+
+	family := func(model string) string {
+		if mp, ok := LookupPricing(model); ok {
+			return mp.Family
+		}
+		return model
+	}
+
+	var months []string
+	cells := make(map[[2]string]float64)
+	familyCost := make(map[string]float64)
+	monthCost := make(map[string]float64)
+	for _, e := range r.MonthlyModelBreakdown {
+		if len(months) == 0 || months[len(months)-1] != e.Month {
+			months = append(months, e.Month)
+		}
+		f := family(e.Model)
+		cells[[2]string{e.Month, f}] += e.Totals.CostUSD
+		familyCost[f] += e.Totals.CostUSD
+		monthCost[e.Month] += e.Totals.CostUSD
+	}
+
+	var families []string
+	for f := range familyCost {
+		families = append(families, f)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return familyCost[families[i]] > familyCost[families[j]]
+	})
+
+	header := fmt.Sprintf("  %-8s", "Month")
+	for _, f := range families {
+		header += "  " + padCellLeft(p.modelName(f), 12)
+	}
+	header += fmt.Sprintf("  %10s", "Total")
+	p.println(p.dim(header))
+	p.println("  " + strings.Repeat("─", 8+14*len(families)+12))
+
+	for _, m := range months {
+		row := fmt.Sprintf("  %-8s", m)
+		for _, f := range families {
+			cost, ok := cells[[2]string{m, f}]
+			if !ok {
+				row += fmt.Sprintf("  %12s", "—")
+				continue
+			}
+			row += fmt.Sprintf("  %12s", p.cost(cost))
+		}
+		row += fmt.Sprintf("  %10s", p.cost(monthCost[m]))
+		p.println(row)
+	}
 	p.println("")
 }
 
@@ -326,14 +704,32 @@ func printProjects(p *Printer, r *AggregatedReport) {
 	if len(r.Projects) == 0 {
 		return
 	}
-	sectionHeader(p, "PROJECTS BY TOKEN USAGE")
+	projects := r.Projects // already by tokens
+	title := "PROJECTS BY TOKEN USAGE"
+	if p.opts.SortBy == "cost" || p.opts.SortBy == "recency" {
+		projects = make([]*ProjectSummary, len(r.Projects))
+		copy(projects, r.Projects)
+	}
+	switch p.opts.SortBy {
+	case "cost":
+		sort.SliceStable(projects, func(i, j int) bool {
+			return projects[i].Totals.CostUSD > projects[j].Totals.CostUSD
+		})
+		title = "PROJECTS BY COST"
+	case "recency":
+		sort.SliceStable(projects, func(i, j int) bool {
+			return projects[i].LastActiveTime.After(projects[j].LastActiveTime)
+		})
+		title = "PROJECTS BY RECENT ACTIVITY"
+	}
+	sectionHeader(p, title)
 
-	header := fmt.Sprintf("  %-3s  %-24s  %14s  %10s  %8s  %8s",
-		"#", "Project", "Total Tokens", "Cache Eff.", "Cost", "Sessions")
+	header := fmt.Sprintf("  %-3s  %-24s  %14s  %10s  %8s  %8s  %12s",
+		"#", "Project", "Total Tokens", "Cache Eff.", "Cost", "Sessions", "Active")
 	p.println(p.dim(header))
-	p.println("  " + strings.Repeat("─", 78))
+	p.println("  " + strings.Repeat("─", 92))
 
-	for i, proj := range r.Projects {
+	for i, proj := range projects {
 		eff := proj.Totals.CacheEfficiency()
 		effFmt := fmtPct(eff)
 		if eff >= 0.75 {
@@ -343,19 +739,67 @@ func printProjects(p *Printer, r *AggregatedReport) {
 		} else {
 			effFmt = p.red(effFmt)
 		}
-		p.printf("  %-3d  %-24s  %14s  %10s  %8s  %8d\n",
+		p.printf("  %-3d  %s  %14s  %10s  %8s  %8d  %12s\n",
 			i+1,
-			truncate(proj.Name, 24),
-			fmtTokens(proj.Totals.TotalTokens()),
+			padCell(proj.Name, 24),
+			p.tokens(proj.Totals.TotalTokens()),
 			effFmt,
-			fmtCost(proj.Totals.CostUSD),
+			p.cost(proj.Totals.CostUSD),
 			proj.SessionCount,
+			fmtTime(proj.LastActiveTime),
 		)
-		p.println(p.gray("       " + truncate(proj.Path, 70)))
+		path := proj.Path
+		if proj.DataDir != "" {
+			path += "  [" + proj.DataDir + "]"
+		}
+		p.println(p.gray("       " + truncate(path, 70)))
+		if p.opts.ShowSessions {
+			printProjectSessions(p, proj)
+		}
 	}
 	p.println("")
 }
 
+// branchLabel renders a session's predominant branch, noting any others.
+func branchLabel(sess *SessionSummary) string {
+	if sess.GitBranch == "" {
+		return ""
+	}
+	label := truncate(sess.GitBranch, 24)
+	if len(sess.Branches) > 1 {
+		label += fmt.Sprintf(" +%d", len(sess.Branches)-1)
+	}
+	return label
+}
+
+// printProjectSessions lists a project's biggest sessions as a tree under
+// its row in the PROJECTS table.
+func printProjectSessions(p *Printer, proj *ProjectSummary) {
+	sessions := make([]*SessionSummary, len(proj.Sessions))
+	copy(sessions, proj.Sessions)
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CombinedTokens() > sessions[j].CombinedTokens()
+	})
+	limit := p.opts.SessionsPerProject
+	if limit <= 0 || limit > len(sessions) {
+		limit = len(sessions)
+	}
+	for i, sess := range sessions[:limit] {
+		prefix := "├─"
+		if i == limit-1 {
+			prefix = "└─"
+		}
+		p.printf("       %s %-12s  %-14s  %14s  %8s  %s\n",
+			p.gray(prefix),
+			shortSession(sess.SessionID),
+			fmtTime(sess.StartTime),
+			p.tokens(sess.CombinedTokens()),
+			p.cost(sess.Totals.CostUSD+sess.SubagentTotals.CostUSD),
+			p.dim(branchLabel(sess)),
+		)
+	}
+}
+
 func printSessions(p *Printer, r *AggregatedReport) {
 	if len(r.Sessions) == 0 {
 		return
@@ -367,26 +811,59 @@ func printSessions(p *Printer, r *AggregatedReport) {
 		limit = len(r.Sessions)
 	}
 
-	header := fmt.Sprintf("  %-3s  %-12s  %-18s  %-14s  %12s  %12s  %8s",
-		"#", "Session", "Project", "Started", "Tokens", "Subagent", "Cost")
+	// The full table is ~110 columns; drop the Subagent column on narrow terminals.
+	showSub := p.opts.Width == 0 || p.opts.Width >= 112
+
+	header := fmt.Sprintf("  %-3s  %-12s  %-18s  %-14s  %8s  %6s  %12s",
+		"#", "Session", "Project", "Started", "Duration", "Msgs", "Tokens")
+	ruleWidth := 92
+	if showSub {
+		header += fmt.Sprintf("  %12s", "Subagent")
+		ruleWidth += 14
+	}
+	header += fmt.Sprintf("  %8s", "Cost")
+
+	// Branch column only when at least one listed session recorded a branch
+	showBranch := false
+	for _, sess := range r.Sessions[:limit] {
+		if sess.GitBranch != "" {
+			showBranch = true
+			break
+		}
+	}
+	if showBranch {
+		header += "  Branch"
+		ruleWidth += 20
+	}
 	p.println(p.dim(header))
-	p.println("  " + strings.Repeat("─", 92))
+	p.println("  " + strings.Repeat("─", ruleWidth))
 
 	for i, sess := range r.Sessions[:limit] {
-		combined := fmtTokens(sess.Totals.TotalTokens())
-		subStr := "—"
-		if sess.SubagentTotals.TotalTokens() > 0 {
-			subStr = fmtTokens(sess.SubagentTotals.TotalTokens())
-		}
-		p.printf("  %-3d  %-12s  %-18s  %-14s  %12s  %12s  %8s\n",
+		combined := p.tokens(sess.Totals.TotalTokens())
+		row := fmt.Sprintf("  %-3d  %-12s  %s  %-14s  %8s  %6d  %12s",
 			i+1,
 			shortSession(sess.SessionID),
-			truncate(sess.ProjectName, 18),
+			padCell(sess.ProjectName, 18),
 			fmtTime(sess.StartTime),
+			fmtDuration(sess.Duration()),
+			sess.Totals.MessageCount,
 			combined,
-			subStr,
-			fmtCost(sess.Totals.CostUSD+sess.SubagentTotals.CostUSD),
 		)
+		if showSub {
+			subStr := "—"
+			if sess.SubagentTotals.TotalTokens() > 0 {
+				subStr = p.tokens(sess.SubagentTotals.TotalTokens())
+			}
+			row += fmt.Sprintf("  %12s", subStr)
+		}
+		row += fmt.Sprintf("  %8s", p.cost(sess.Totals.CostUSD+sess.SubagentTotals.CostUSD))
+		if showBranch {
+			row += "  " + p.dim(branchLabel(sess))
+		}
+		p.println(row)
+		if p.opts.ExpandSessions {
+			printSessionModels(p, sess)
+		}
 	}
 	if len(r.Sessions) > limit {
 		p.println(p.gray(fmt.Sprintf("  … and %d more sessions", len(r.Sessions)-limit)))
@@ -394,65 +871,256 @@ func printSessions(p *Printer, r *AggregatedReport) {
 	p.println("")
 }
 
+// maxExpandedModels is how many models --expand-sessions lists per session.
+const maxExpandedModels = 3
+
+// printSessionModels prints a session's time range and its largest models
+// as a tree under its TOP SESSIONS row.
+func printSessionModels(p *Printer, sess *SessionSummary) {
+	p.println(p.gray(fmt.Sprintf("       %s – %s", fmtTime(sess.StartTime), fmtTime(sess.EndTime))))
+	models := make([]string, 0, len(sess.ModelBreakdown))
+	for m := range sess.ModelBreakdown {
+		models = append(models, m)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		ti, tj := sess.ModelBreakdown[models[i]].TotalTokens(), sess.ModelBreakdown[models[j]].TotalTokens()
+		if ti != tj {
+			return ti > tj
+		}
+		return models[i] < models[j]
+	})
+	limit := min(len(models), maxExpandedModels)
+	for i, m := range models[:limit] {
+		prefix := "├─"
+		if i == limit-1 && len(models) == limit {
+			prefix = "└─"
+		}
+		t := sess.ModelBreakdown[m]
+		p.printf("       %s %s  %14s  %8s\n",
+			p.gray(prefix), padCell(p.modelName(m), 28), p.tokens(t.TotalTokens()), p.cost(t.CostUSD))
+	}
+	if len(models) > limit {
+		p.println(p.gray(fmt.Sprintf("       └─ … and %d more models", len(models)-limit)))
+	}
+}
+
+// printSidechains lists every session with isSidechain records, largest
+// sidechain token total first.
+func printSidechains(p *Printer, r *AggregatedReport) {
+	sectionHeader(p, "SIDECHAIN BREAKDOWN")
+
+	var sessions []*SessionSummary
+	for _, sess := range r.Sessions {
+		if sess.SidechainTotals.MessageCount > 0 {
+			sessions = append(sessions, sess)
+		}
+	}
+	if len(sessions) == 0 {
+		p.println(p.gray("  No sidechain records in this period."))
+		p.println("")
+		return
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].SidechainTotals.TotalTokens() > sessions[j].SidechainTotals.TotalTokens()
+	})
+
+	p.println(p.dim(fmt.Sprintf("  %-3s  %-12s  %-18s  %6s  %12s  %8s",
+		"#", "Session", "Project", "Msgs", "Tokens", "Cost")))
+	p.println("  " + strings.Repeat("─", 69))
+	for i, sess := range sessions {
+		t := sess.SidechainTotals
+		p.printf("  %-3d  %-12s  %s  %6d  %12s  %8s\n",
+			i+1,
+			shortSession(sess.SessionID),
+			padCell(sess.ProjectName, 18),
+			t.MessageCount,
+			p.tokens(t.TotalTokens()),
+			p.cost(t.CostUSD),
+		)
+	}
+	p.println("")
+}
+
 func printDailyTrend(p *Printer, r *AggregatedReport) {
+	if len(r.Weekly) > 0 {
+		printWeeklyTrend(p, r)
+		return
+	}
 	if len(r.Daily) == 0 {
 		return
 	}
 	sectionHeader(p, "DAILY TOKEN TREND")
 
-	// Extract daily totals for sparkline
-	vals := make([]int64, len(r.Daily))
-	var maxVal int64
-	for i, d := range r.Daily {
-		vals[i] = d.Totals.TotalTokens()
-		if vals[i] > maxVal {
-			maxVal = vals[i]
+	// Long windows get a fixed-width overview line above the per-day rows.
+	if len(r.Daily) > 30 {
+		vals := make([]int64, len(r.Daily))
+		for i, d := range r.Daily {
+			vals[i] = d.Totals.TotalTokens()
 		}
+		p.printf("  %s → %s  %s\n", r.Daily[0].Date, r.Daily[len(r.Daily)-1].Date,
+			p.cyan(SparklineNormalized(vals, 30)))
+		p.println("")
 	}
 
-	spark := sparkline(vals)
-	runes := []rune(spark)
+	var maxVal float64
+	for _, d := range r.Daily {
+		maxVal = math.Max(maxVal, p.trendValue(d.Totals))
+	}
 
-	for i, d := range r.Daily {
-		var bar string
-		if i < len(runes) {
-			bar = string(runes[i])
-		}
+	for _, d := range r.Daily {
 		tokens := d.Totals.TotalTokens()
-
-		var tokenFmt string
+		tokenFmt := fmt.Sprintf("%14s", p.tokens(tokens))
 		if tokens == 0 {
-			tokenFmt = p.gray("0")
-		} else {
-			tokenFmt = fmtTokens(tokens)
+			tokenFmt = p.gray(tokenFmt)
 		}
+		// Filled days are also labelled, so they stand out without color.
+		date, note := d.Date, ""
+		if d.Filled {
+			date, note = p.dim(date), "  "+p.gray("(no data)")
+		}
+		p.printf("  %s  %s  %s  %8s%s\n",
+			date, trendBar(p, p.trendValue(d.Totals), maxVal), tokenFmt, p.cost(d.Totals.CostUSD), note)
+	}
+	p.println("")
+}
+
+func printWeeklyTrend(p *Printer, r *AggregatedReport) {
+	sectionHeader(p, "WEEKLY TOKEN TREND")
+
+	var maxVal float64
+	for _, w := range r.Weekly {
+		maxVal = math.Max(maxVal, p.trendValue(w.Totals))
+	}
 
-		// Print individual bar for each day using block chars scaled to 20 width
-		barWidth := 20
-		var dayBar string
+	for _, w := range r.Weekly {
+		tokens := w.Totals.TotalTokens()
+		tokenFmt := fmt.Sprintf("%14s", p.tokens(tokens))
 		if tokens == 0 {
-			dayBar = p.gray(strings.Repeat("░", barWidth))
-		} else {
-			filled := int(math.Round(float64(tokens) / float64(maxVal) * float64(barWidth)))
-			if filled == 0 {
-				filled = 1
+			tokenFmt = p.gray(tokenFmt)
+		}
+		p.printf("  %-8s  %s  %s  %s  %8s\n",
+			w.WeekLabel, trendBar(p, p.trendValue(w.Totals), maxVal),
+			p.gray(sparkline(w.DayTokens[:])), tokenFmt, p.cost(w.Totals.CostUSD))
+	}
+	p.println("")
+}
+
+// trendValue returns the quantity the trend bars are scaled by.
+func (p *Printer) trendValue(t UsageTotals) float64 {
+	if p.opts.TrendMetric == "cost" {
+		return t.CostUSD
+	}
+	return float64(t.TotalTokens())
+}
+
+// trendBar renders a 20-wide block bar for val relative to maxVal.
+func trendBar(p *Printer, val, maxVal float64) string {
+	const barWidth = 20
+	if val <= 0 || maxVal <= 0 {
+		return p.gray(strings.Repeat("░", barWidth))
+	}
+	filled := int(math.Round(val / maxVal * barWidth))
+	if filled == 0 {
+		filled = 1
+	}
+	return p.cyan(strings.Repeat("█", filled)) + p.gray(strings.Repeat("░", barWidth-filled))
+}
+
+// ---- Activity calendar ----
+
+var calendarShades = []rune{'·', '░', '▒', '▓', '█'}
+
+// printCalendar renders a GitHub-style heatmap (weeks as columns, weekdays
+// as rows) of the last year of activity. Only shown for periods over 8 weeks.
+func printCalendar(p *Printer, r *AggregatedReport) {
+	if len(r.AllDaily) == 0 || r.DateTo.Sub(r.DateFrom) < 8*7*24*time.Hour {
+		return
+	}
+
+	byDate := make(map[string]int64, len(r.AllDaily))
+	var maxVal int64
+	for _, d := range r.AllDaily {
+		t := d.Totals.TotalTokens()
+		byDate[d.Date] = t
+		if t > maxVal {
+			maxVal = t
+		}
+	}
+	if maxVal == 0 {
+		return
+	}
+
+	last, err := time.Parse("2006-01-02", r.AllDaily[len(r.AllDaily)-1].Date)
+	if err != nil {
+		return
+	}
+	first, _ := time.Parse("2006-01-02", r.AllDaily[0].Date)
+	const maxWeeks = 53
+	start := mondayOf(first)
+	if earliest := mondayOf(last).AddDate(0, 0, -7*(maxWeeks-1)); start.Before(earliest) {
+		start = earliest
+	}
+	weeks := int(mondayOf(last).Sub(start).Hours()/24/7) + 1
+
+	sectionHeader(p, "ACTIVITY CALENDAR")
+
+	// Month axis: label the first week column that contains the 1st.
+	axis := []rune(strings.Repeat(" ", weeks+3))
+	nextFree := 0
+	for w := 0; w < weeks; w++ {
+		weekStart := start.AddDate(0, 0, 7*w)
+		for d := 0; d < 7; d++ {
+			day := weekStart.AddDate(0, 0, d)
+			if day.Day() != 1 && !(w == 0 && d == 0) {
+				continue
+			}
+			if w >= nextFree {
+				copy(axis[w:], []rune(day.Format("Jan")))
+				nextFree = w + 4
 			}
-			dayBar = p.cyan(strings.Repeat("█", filled)) + p.gray(strings.Repeat("░", barWidth-filled))
+			break
 		}
+	}
+	p.println("       " + p.gray(strings.TrimRight(string(axis), " ")))
 
-		_ = bar // sparkline char used for reference
-		p.printf("  %s  %s  %s\n", d.Date, dayBar, tokenFmt)
+	dayLabels := []string{"Mon", "", "Wed", "", "Fri", "", "Sun"}
+	for d := 0; d < 7; d++ {
+		var sb strings.Builder
+		for w := 0; w < weeks; w++ {
+			day := start.AddDate(0, 0, 7*w+d)
+			if day.After(last) {
+				break
+			}
+			tokens := byDate[day.Format("2006-01-02")]
+			level := 0
+			if tokens > 0 {
+				level = 1 + int(float64(tokens)/float64(maxVal)*float64(len(calendarShades)-2)+0.5)
+				if level >= len(calendarShades) {
+					level = len(calendarShades) - 1
+				}
+			}
+			cell := string(calendarShades[level])
+			if level == 0 {
+				cell = p.gray(cell)
+			} else {
+				cell = p.green(cell)
+			}
+			sb.WriteString(cell)
+		}
+		p.printf("  %-3s  %s\n", dayLabels[d], sb.String())
 	}
+	p.printf("\n       %s\n", p.gray("less "+string(calendarShades)+" more"))
 	p.println("")
 }
 
 func printInsights(p *Printer, r *AggregatedReport) {
-	if len(r.Insights) == 0 {
+	insights, suppressed := FilterInsights(r.Insights, p.opts.MinSeverity)
+	if len(insights) == 0 && suppressed == 0 {
 		return
 	}
 	sectionHeader(p, "INSIGHTS")
 
-	for _, ins := range r.Insights {
+	for _, ins := range insights {
 		var tag string
 		var msgFmt func(string) string
 		switch ins.Severity {
@@ -467,7 +1135,7 @@ func printInsights(p *Printer, r *AggregatedReport) {
 			msgFmt = func(s string) string { return s }
 		}
 		// Word-wrap at ~70 chars
-		wrapped := wordWrap(ins.Message, 68)
+		wrapped := WordWrapCJK(ins.Message, 68)
 		lines := strings.Split(wrapped, "\n")
 		p.printf("  %s  %s\n", tag, msgFmt(lines[0]))
 		for _, line := range lines[1:] {
@@ -475,10 +1143,16 @@ func printInsights(p *Printer, r *AggregatedReport) {
 		}
 		p.println("")
 	}
+	if suppressed > 0 {
+		p.println(p.dim(fmt.Sprintf("  %d lower-severity insight(s) hidden by --min-severity", suppressed)))
+		p.println("")
+	}
 }
 
-// wordWrap wraps s at width characters, breaking at spaces.
-func wordWrap(s string, width int) string {
+// WordWrapCJK wraps s to width terminal cells, breaking at spaces. A run
+// with no spaces that is wider than a line on its own, such as Chinese or
+// Japanese text, is broken between characters instead.
+func WordWrapCJK(s string, width int) string {
 	words := strings.Fields(s)
 	if len(words) == 0 {
 		return s
@@ -486,16 +1160,36 @@ func wordWrap(s string, width int) string {
 	var sb strings.Builder
 	lineLen := 0
 	for i, w := range words {
-		wLen := utf8.RuneCountInString(w)
-		if i > 0 && lineLen+1+wLen > width {
+		wLen := displayWidth(w)
+		first, _ := utf8.DecodeRuneInString(w)
+		switch {
+		case i == 0:
+		case wLen > width && lineLen+1+runeWidth(first) <= width:
+			// Too wide for any line: start it here rather than leave this
+			// line short, and let the loop below break it.
+			sb.WriteByte(' ')
+			lineLen++
+		case lineLen+1+wLen > width:
 			sb.WriteByte('\n')
 			lineLen = 0
-		} else if i > 0 {
+		default:
 			sb.WriteByte(' ')
 			lineLen++
 		}
-		sb.WriteString(w)
-		lineLen += wLen
+		if wLen <= width {
+			sb.WriteString(w)
+			lineLen += wLen
+			continue
+		}
+		for _, r := range w {
+			rw := runeWidth(r)
+			if lineLen > 0 && lineLen+rw > width {
+				sb.WriteByte('\n')
+				lineLen = 0
+			}
+			sb.WriteRune(r)
+			lineLen += rw
+		}
 	}
 	return sb.String()
 }
@@ -510,10 +1204,13 @@ func max(a, b int) int {
 // ---- Prompt Clarity section ----
 
 func printClaritySection(p *Printer, r *AggregatedReport) {
+	if r.Clarity == nil {
+		return // clarity analysis was skipped
+	}
 	sectionHeader(p, "PROMPT CLARITY")
 
-	if r.Clarity == nil || r.Clarity.SessionCount < 2 {
-		p.println("  Not enough data yet (need 2+ sessions)")
+	if r.Clarity.ScoredSessionCount < 2 {
+		p.println("  Not enough data yet (need 2+ sessions with 2+ prompts each)")
 		p.println("")
 		return
 	}
@@ -590,6 +1287,38 @@ func printClaritySection(p *Printer, r *AggregatedReport) {
 		p.println("")
 	}
 
+	// Per-project ranking, worst first
+	if len(cl.ScoreByProject) >= 2 {
+		p.printf("  %-22s  %s\n", "By project", p.gray(fmt.Sprintf("%5s  %8s", "Score", "Sessions")))
+		for _, pr := range cl.ScoreByProject {
+			p.printf("    %s  %5d  %8d\n", padCell(pr.ProjectName, 20), int(math.Round(pr.Score)), pr.SessionCount)
+		}
+		p.println("")
+	}
+
+	// Front-loading per project, worst first
+	if len(cl.FrontLoadByProject) >= 3 {
+		keys := make([]string, 0, len(cl.FrontLoadByProject))
+		for key := range cl.FrontLoadByProject {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := cl.FrontLoadByProject[keys[i]], cl.FrontLoadByProject[keys[j]]
+			if a != b {
+				return a < b
+			}
+			if a, b := cl.ProjectName(keys[i]), cl.ProjectName(keys[j]); a != b {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+		p.printf("  %-22s  %s\n", "Front-loading", p.gray(fmt.Sprintf("%6s", "Ratio")))
+		for _, key := range keys {
+			p.printf("    %s  %5.1f%%\n", padCell(cl.ProjectName(key), 20), cl.FrontLoadByProject[key]*100)
+		}
+		p.println("")
+	}
+
 	// Individual metric rows
 	printClarityMetricRow(p, "Correction Rate", cl.Overall.CorrectionRate, "↓ lower is better",
 		CorrectionRateInsight(cl.Overall.CorrectionRate), MetricDescriptions["correction_rate"],
@@ -597,9 +1326,28 @@ func printClaritySection(p *Printer, r *AggregatedReport) {
 	printClarityMetricRow(p, "Clarification Rate", cl.Overall.ClarificationRate, "↓ lower is better",
 		ClarificationRateInsight(cl.Overall.ClarificationRate), MetricDescriptions["clarification_rate"],
 		nil)
+	printClarificationPhrases(p, cl.ClarificationPhraseFrequency)
 	printClarityMetricRow(p, "Front-load Ratio", cl.Overall.FrontLoadRatio, "↑ higher is better",
 		FrontLoadRatioInsight(cl.Overall.FrontLoadRatio), MetricDescriptions["front_load_ratio"],
 		nil)
+
+	// First-message length is in words, not a percentage.
+	fw := FirstMessageWordsInsight(cl.Overall.FirstMessageWordsAvg)
+	fwBadge := p.green("[good]")
+	if fw.Level == "warn" {
+		fwBadge = p.red("[warn]")
+	}
+	p.printf("  %-22s  %5.0f words  %s  %s\n", "First message", cl.Overall.FirstMessageWordsAvg, p.gray("↑ higher is better"), fwBadge)
+	if fw.Level == "warn" {
+		p.printf("    %s\n", p.dim(`"`+fw.Oneliner+`"`))
+	}
+	p.printf("    %s\n", p.gray(MetricDescriptions["first_message_words"]))
+	p.println("")
+
+	// Tool call rate is descriptive, not graded: neither end is better.
+	p.printf("  %-22s  %5.1f%%\n", "Tool call rate", cl.ToolCallRate*100)
+	p.printf("    %s\n", p.gray("High = agentic mode; low = conversational mode"))
+	p.println("")
 }
 
 // ---- Coaching tip section ----
@@ -677,7 +1425,7 @@ func printOneTip(p *Printer, tip *CoachingTip, cl *ClarityReport, showDelta bool
 	p.printf("  %s\n", p.bold(tip.Headline))
 	p.printf("  %s\n", p.dim(strings.Repeat("─", len(tip.Headline))))
 
-	wrapped := wordWrap(tip.Technique, 68)
+	wrapped := WordWrapCJK(tip.Technique, 68)
 	for _, line := range strings.Split(wrapped, "\n") {
 		p.printf("  %s\n", line)
 	}
@@ -698,6 +1446,33 @@ func printOneTip(p *Printer, tip *CoachingTip, cl *ClarityReport, showDelta bool
 	p.println("")
 }
 
+// printClarificationPhrases lists the phrases behind the clarification rate,
+// most frequent first, as a dim sub-table.
+func printClarificationPhrases(p *Printer, freq map[string]int) {
+	if len(freq) == 0 {
+		return
+	}
+	phrases := make([]string, 0, len(freq))
+	for ph := range freq {
+		phrases = append(phrases, ph)
+	}
+	sort.Slice(phrases, func(i, j int) bool {
+		if freq[phrases[i]] != freq[phrases[j]] {
+			return freq[phrases[i]] > freq[phrases[j]]
+		}
+		return phrases[i] < phrases[j]
+	})
+	p.printf("    %s\n", p.dim("Triggering phrases"))
+	for i, ph := range phrases {
+		prefix := "├─"
+		if i == len(phrases)-1 {
+			prefix = "└─"
+		}
+		p.printf("    %s\n", p.dim(fmt.Sprintf("%s %-24s %4d", prefix, `"`+ph+`"`, freq[ph])))
+	}
+	p.println("")
+}
+
 func printClarityMetricRow(p *Printer, name string, val float64, direction string, ins MetricInsight, description string, subBreakdown map[string]float64) {
 	var badge string
 	switch ins.Level {
@@ -740,3 +1515,35 @@ func printClarityMetricRow(p *Printer, name string, val float64, direction strin
 
 	p.println("")
 }
+
+// PrintPricingTable writes the built-in per-million-token rates for
+// --model-pricing-table. Families with Notes get a numbered marker and a dim
+// footnote; identical notes share one number.
+func PrintPricingTable(w io.Writer, useColors bool) {
+	p := &Printer{w: w, useColors: useColors}
+
+	var notes []string
+	noteNum := make(map[string]int)
+	p.println(p.bold("MODEL PRICING") + p.dim("  (USD per million tokens)"))
+	p.println(p.dim(fmt.Sprintf("  %-20s  %8s  %8s  %11s  %10s", "Family", "Input", "Output", "Cache write", "Cache read")))
+	for _, m := range pricingTable {
+		marker := ""
+		if m.Notes != "" {
+			n, ok := noteNum[m.Notes]
+			if !ok {
+				notes = append(notes, m.Notes)
+				n = len(notes)
+				noteNum[m.Notes] = n
+			}
+			marker = fmt.Sprintf(" [%d]", n)
+		}
+		p.printf("  %-20s  %8.2f  %8.2f  %11.2f  %10.2f%s\n",
+			m.Family, m.InputPerMTok, m.OutputPerMTok, m.CacheWritePerMTok, m.CacheReadPerMTok, p.dim(marker))
+	}
+	if len(notes) > 0 {
+		p.println("")
+		for i, n := range notes {
+			p.println(p.dim(fmt.Sprintf("  [%d] %s", i+1, n)))
+		}
+	}
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name, or rewrites it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update if the change is intended)\n--- got ---\n%s", path, got)
+	}
+}
+
+// withUTC makes the local zone UTC for the rest of the test, so printed
+// times do not depend on the machine.
+func withUTC(t *testing.T) {
+	saved := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = saved })
+}
+
+// fixtureTotals returns the totals of one batch of messages for model.
+func fixtureTotals(model string, in, out, write, read, msgs int64) UsageTotals {
+	u := TokenUsage{
+		InputTokens:              int(in),
+		OutputTokens:             int(out),
+		CacheCreationInputTokens: int(write),
+		CacheReadInputTokens:     int(read),
+	}
+	var t UsageTotals
+	t.Add(u, ComputeCostBreakdown(model, u))
+	t.MessageCount = msgs
+	return t
+}
+
+func sumTotals(ts ...UsageTotals) UsageTotals {
+	var sum UsageTotals
+	for _, t := range ts {
+		sum.InputTokens += t.InputTokens
+		sum.OutputTokens += t.OutputTokens
+		sum.CacheCreationInputTokens += t.CacheCreationInputTokens
+		sum.CacheReadInputTokens += t.CacheReadInputTokens
+		sum.MessageCount += t.MessageCount
+		sum.CostUSD += t.CostUSD
+		sum.CacheWriteCostUSD += t.CacheWriteCostUSD
+		sum.CacheReadCostUSD += t.CacheReadCostUSD
+		sum.CacheUncachedCostUSD += t.CacheUncachedCostUSD
+	}
+	return sum
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// fixtureReport is a small, fixed report: two projects, three sessions, two
+// models over three days.
+func fixtureReport() *AggregatedReport {
+	const sonnet, opus = "claude-sonnet-4-5-20250929", "claude-opus-4-1-20250805"
+	day := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, time.UTC) }
+
+	s1 := fixtureTotals(sonnet, 1200, 45_000, 80_000, 1_500_000, 40)
+	s2 := fixtureTotals(opus, 300, 12_000, 20_000, 250_000, 12)
+	s3 := fixtureTotals(sonnet, 150, 6_000, 9_000, 90_000, 8)
+	sub := fixtureTotals(sonnet, 50, 3_000, 4_000, 30_000, 5)
+
+	sessions := []*SessionSummary{
+		{
+			SessionID: "aaaaaaaa-1111-1111-1111-111111111111", ProjectName: "api", ProjectSlug: "-work-api",
+			StartTime: day(1, 9), EndTime: day(1, 11), Totals: s1, SubagentTotals: sub,
+			ModelBreakdown: map[string]*UsageTotals{sonnet: ptr(sumTotals(s1, sub))},
+			GitBranch:      "main", Branches: []string{"main"}, BranchCount: 1,
+			DurationSeconds: 7200, MessageCount: s1.MessageCount, UserMessageCount: 6, ToolResultCount: 20,
+		},
+		{
+			SessionID: "bbbbbbbb-2222-2222-2222-222222222222", ProjectName: "web", ProjectSlug: "-work-web",
+			StartTime: day(2, 14), EndTime: day(2, 15), Totals: s2,
+			ModelBreakdown: map[string]*UsageTotals{opus: ptr(s2)},
+			GitBranch:      "feature", Branches: []string{"feature"}, BranchCount: 1,
+			DurationSeconds: 3600, MessageCount: s2.MessageCount, UserMessageCount: 3, ToolResultCount: 5,
+		},
+		{
+			SessionID: "cccccccc-3333-3333-3333-333333333333", ProjectName: "api", ProjectSlug: "-work-api",
+			StartTime: day(4, 8), EndTime: day(4, 8).Add(20 * time.Minute), Totals: s3,
+			ModelBreakdown: map[string]*UsageTotals{sonnet: ptr(s3)},
+			GitBranch:      "main", Branches: []string{"main"}, BranchCount: 1,
+			DurationSeconds: 1200, MessageCount: s3.MessageCount, UserMessageCount: 2, ToolResultCount: 1,
+		},
+	}
+	api := sumTotals(s1, sub, s3)
+	grand := sumTotals(s1, sub, s2, s3)
+	daily := []DailySummary{
+		{Date: "2026-10-01", Totals: sumTotals(s1, sub)},
+		{Date: "2026-10-02", Totals: s2},
+		{Date: "2026-10-04", Totals: s3},
+	}
+	for i := range daily {
+		daily[i].DailyCostUSD = daily[i].Totals.CostUSD
+		daily[i].DailyTotalTokens = daily[i].Totals.TotalTokens()
+	}
+	hourly := make([]HourlySummary, 24)
+	for h := range hourly {
+		hourly[h].Hour = h
+	}
+	hourly[9].Totals, hourly[14].Totals, hourly[8].Totals = sumTotals(s1, sub), s2, s3
+
+	return &AggregatedReport{
+		Grand:       grand,
+		GrandByType: map[string]*UsageTotals{"main": ptr(sumTotals(s1, s2, s3)), "subagent": ptr(sub)},
+		ModelSummaries: map[string]*UsageTotals{
+			sonnet: ptr(sumTotals(s1, sub, s3)),
+			opus:   ptr(s2),
+		},
+		Projects: []*ProjectSummary{
+			{
+				Slug: "-work-api", Name: "api", Path: "/work/api", Totals: api,
+				SessionCount: 2, SubagentCount: 1,
+				ModelBreakdown: map[string]*UsageTotals{sonnet: ptr(api)},
+				Sessions:       []*SessionSummary{sessions[0], sessions[2]},
+				LastActiveTime: sessions[2].EndTime,
+			},
+			{
+				Slug: "-work-web", Name: "web", Path: "/work/web", Totals: s2,
+				SessionCount:   1,
+				ModelBreakdown: map[string]*UsageTotals{opus: ptr(s2)},
+				Sessions:       []*SessionSummary{sessions[1]},
+				LastActiveTime: sessions[1].EndTime,
+			},
+		},
+		Sessions:           sessions,
+		Daily:              daily,
+		AllDaily:           daily,
+		Hourly:             hourly,
+		UniqueSessionCount: 3,
+		MainFileCount:      3,
+		SubagentFileCount:  1,
+		Insights: []Insight{
+			{Severity: "good", Message: "Cache efficiency is high"},
+			{Severity: "warn", Message: "One session used most of the tokens"},
+		},
+		DateFrom:            day(1, 9),
+		DateTo:              sessions[2].EndTime,
+		Period:              "Oct 01, 2026 – Oct 04, 2026",
+		APIRequests:         grand.MessageCount,
+		AvgCostPerRequest:   grand.CostUSD / float64(grand.MessageCount),
+		AvgOutputPerRequest: float64(grand.OutputTokens) / float64(grand.MessageCount),
+		CacheSavingsUSD:     grand.CacheUncachedCostUSD - grand.CacheReadCostUSD,
+		DaysSinceFirstUse:   15,
+		Percentiles:         Percentiles{P50: 262_300, P90: 1_663_250, P99: 1_663_250},
+		FirstUseDate:        day(1, 9),
+		ModelFirstSeen:      map[string]time.Time{sonnet: day(1, 9), opus: day(2, 14)},
+		ModelLastSeen:       map[string]time.Time{sonnet: sessions[2].EndTime, opus: day(2, 15)},
+		Clarity: &ClarityReport{
+			Overall: ClarityMetrics{
+				CorrectionRate: 0.2, ClarificationRate: 0.25, FrontLoadRatio: 0.55, Score: 70.5,
+				CorrectionsByType:    map[string]float64{"scope": 0.15, "format": 0.05},
+				FirstMessageWordsAvg: 24,
+			},
+			Weekly: []WeeklyClarity{
+				{WeekStart: "2026-09-28", CorrectionRate: 0.2, ClarificationRate: 0.25, FrontLoadRatio: 0.55, Score: 70.5, SessionCount: 3},
+			},
+			ScoreByProject: []ProjectClarityRank{
+				{ProjectKey: "-work-web", ProjectName: "web", Score: 64, SessionCount: 1},
+				{ProjectKey: "-work-api", ProjectName: "api", Score: 73.75, SessionCount: 2},
+			},
+			FrontLoadByProject: map[string]float64{"-work-api": 0.6, "-work-web": 0.45},
+			SessionCount:       3,
+			ScoredSessionCount: 3,
+			ToolCallRate:       0.6,
+			AvgResponseLength:  850,
+			HourlyBuckets:      nil,
+			BestHour:           -1,
+			WorstHour:          -1,
+		},
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	withUTC(t)
+	var buf bytes.Buffer
+	PrintReport(&buf, fixtureReport(), false, PrintOptions{})
+	checkGolden(t, "report.txt", buf.Bytes())
+}
+
+func TestPrintReportColor(t *testing.T) {
+	withUTC(t)
+	var buf bytes.Buffer
+	PrintReport(&buf, fixtureReport(), true, PrintOptions{})
+	checkGolden(t, "report_color.txt", buf.Bytes())
+}
+
+func TestPrintReportOptions(t *testing.T) {
+	withUTC(t)
+	var buf bytes.Buffer
+	PrintReport(&buf, fixtureReport(), false, PrintOptions{
+		ShowSessions: true, ExpandSessions: true, MonthlyModels: true, Abbrev: true, SortBy: "cost",
+	})
+	checkGolden(t, "report_options.txt", buf.Bytes())
+}
+
+func TestBreakdown(t *testing.T) {
+	for _, kind := range breakdownKinds {
+		t.Run(kind, func(t *testing.T) {
+			var tsv, aligned, csv bytes.Buffer
+			r := fixtureReport()
+			if err := PrintBreakdown(&tsv, r, kind, false); err != nil {
+				t.Fatal(err)
+			}
+			if err := PrintBreakdown(&aligned, r, kind, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteBreakdownCSV(&csv, r, kind); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "breakdown_"+kind+".tsv", tsv.Bytes())
+			checkGolden(t, "breakdown_"+kind+".txt", aligned.Bytes())
+			checkGolden(t, "breakdown_"+kind+".csv", csv.Bytes())
+		})
+	}
+	if err := PrintBreakdown(&bytes.Buffer{}, fixtureReport(), "branch", false); err == nil {
+		t.Error("PrintBreakdown accepted an unknown kind")
+	}
+}
+
+func TestFmtInt(t *testing.T) {
+	for _, tt := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{2400, "2,400"},
+		{1234567, "1,234,567"},
+		{-123, "-123"},
+		{-1234, "-1,234"},
+	} {
+		if got := fmtInt(tt.n); got != tt.want {
+			t.Errorf("fmtInt(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRuneWidth(t *testing.T) {
+	tests := map[rune]int{
+		'a': 1, 'é': 1, '–': 1, '█': 1,
+		'中': 2, '한': 2, 'ア': 2, '🚀': 2, '！': 2,
+		'\u0301': 0, // combining acute accent
+		'\u200d': 0, // zero-width joiner
+	}
+	for r, want := range tests {
+		if got := runeWidth(r); got != want {
+			t.Errorf("runeWidth(%q) = %d, want %d", r, got, want)
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := map[string]int{
+		"":           0,
+		"api":        3,
+		"café":       4,
+		"cafe\u0301": 4,
+		"数据平台":       8,
+		"🚀-launch":   9,
+		"api-服务":     8,
+	}
+	for s, want := range tests {
+		if got := displayWidth(s); got != want {
+			t.Errorf("displayWidth(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"api", 5, "api"},
+		{"api", 3, "api"},
+		{"backend", 5, "back…"},
+		{"数据平台", 8, "数据平台"},
+		{"数据平台", 7, "数据平…"},
+		{"数据平台", 6, "数据…"}, // 平 would end on the last cell, leaving no room for …
+		{"🚀-launch", 4, "🚀-…"},
+		{"api", 0, ""},
+		{"api", -1, ""},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+		if tt.n > 0 && displayWidth(got) > tt.n {
+			t.Errorf("truncate(%q, %d) is %d cells wide", tt.s, tt.n, displayWidth(got))
+		}
+	}
+}
+
+func TestWordWrapCJK(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"", 10, ""},
+		{"the quick brown fox", 10, "the quick\nbrown fox"},
+		{"数据平台分析工具使用情况", 10, "数据平台分\n析工具使用\n情况"},
+		{"Use 数据平台 for analytics", 10, "Use\n数据平台\nfor\nanalytics"},
+		// A run wider than the line starts after the word before it...
+		{"Tip: 日本語のプロジェクト名", 12, "Tip: 日本語\nのプロジェク\nト名"},
+		{"abc supercalifragilistic", 10, "abc superc\nalifragili\nstic"},
+		// ...unless not even its first character fits there.
+		{"abcdefgh 数据平台分析工具", 10, "abcdefgh\n数据平台分\n析工具"},
+	}
+	for _, tt := range tests {
+		got := WordWrapCJK(tt.s, tt.width)
+		if got != tt.want {
+			t.Errorf("WordWrapCJK(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+		for _, line := range strings.Split(got, "\n") {
+			if displayWidth(line) > tt.width {
+				t.Errorf("WordWrapCJK(%q, %d): line %q is %d cells wide", tt.s, tt.width, line, displayWidth(line))
+			}
+		}
+	}
+}
+
+// wordWrapBytes is WordWrapCJK over a single []byte buffer, without
+// strings.Fields. It allocates less but is no faster in BenchmarkWordWrap,
+// well short of the 30% gain that would justify replacing WordWrapCJK, so
+// it lives here only as the benchmark's baseline.
+func wordWrapBytes(s string, width int) string {
+	buf := make([]byte, 0, len(s)+len(s)/max(width, 1)+1)
+	lineLen, words := 0, 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		start := i
+		for i < len(s) {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		w := s[start:i]
+		wLen := displayWidth(w)
+		first, _ := utf8.DecodeRuneInString(w)
+		switch {
+		case words == 0:
+		case wLen > width && lineLen+1+runeWidth(first) <= width:
+			buf = append(buf, ' ')
+			lineLen++
+		case lineLen+1+wLen > width:
+			buf = append(buf, '\n')
+			lineLen = 0
+		default:
+			buf = append(buf, ' ')
+			lineLen++
+		}
+		words++
+		if wLen <= width {
+			buf = append(buf, w...)
+			lineLen += wLen
+			continue
+		}
+		for _, r := range w {
+			rw := runeWidth(r)
+			if lineLen > 0 && lineLen+rw > width {
+				buf = append(buf, '\n')
+				lineLen = 0
+			}
+			buf = utf8.AppendRune(buf, r)
+			lineLen += rw
+		}
+	}
+	if words == 0 {
+		return s
+	}
+	return string(buf)
+}
+
+// wordWrapInputs are BenchmarkWordWrap's cases: a short insight, a
+// ~200-character tip, and text already wrapped to the width.
+var wordWrapInputs = []struct{ name, s string }{
+	{"short", "Cache efficiency is low (32%)."},
+	{"200", "Your first prompts average 9 words. Front-load the context: name the files to change, " +
+		"the behaviour you want, and what must stay the same, so the first reply does not have to guess."},
+	{"prewrapped", "Sessions that restart often\nlose their prompt cache and\npay full input price for\nthe whole conversation again."},
+}
+
+func TestWordWrapBytes(t *testing.T) {
+	for _, in := range wordWrapInputs {
+		if got, want := wordWrapBytes(in.s, 40), WordWrapCJK(in.s, 40); got != want {
+			t.Errorf("%s: wordWrapBytes = %q, WordWrapCJK = %q", in.name, got, want)
+		}
+	}
+}
+
+func BenchmarkWordWrap(b *testing.B) {
+	impls := []struct {
+		name string
+		wrap func(string, int) string
+	}{
+		{"builder", WordWrapCJK},
+		{"bytes", wordWrapBytes},
+	}
+	for _, impl := range impls {
+		for _, in := range wordWrapInputs {
+			b.Run(impl.name+"/"+in.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					impl.wrap(in.s, 40)
+				}
+			})
+		}
+	}
+}
+
+func TestPadCell(t *testing.T) {
+	for _, s := range []string{"", "api", "café", "数据平台", "数据平台数据平台", "🚀-launch", "a-very-long-project-name"} {
+		for _, n := range []int{0, 1, 6, 8, 12} {
+			if got := displayWidth(padCell(s, n)); got != n {
+				t.Errorf("padCell(%q, %d) is %d cells wide", s, n, got)
+			}
+		}
+	}
+}
+
+// Wide and narrow project names must leave the columns after them aligned.
+func TestPrintReportMixedWidthNames(t *testing.T) {
+	withUTC(t)
+	r := fixtureReport()
+	r.Projects[0].Name, r.Projects[1].Name = "数据平台", "🚀-launch"
+	r.Sessions[0].ProjectName, r.Sessions[2].ProjectName = "数据平台", "数据平台"
+	r.Sessions[1].ProjectName = "🚀-launch"
+
+	var buf bytes.Buffer
+	PrintReport(&buf, r, false, PrintOptions{})
+	lines := strings.Split(buf.String(), "\n")
+
+	// In the table under header, the token value on each named row must end
+	// in the same cell as the column heading.
+	checkTable := func(header, column string, rows map[string]string) {
+		t.Helper()
+		h := -1
+		for i, line := range lines {
+			if strings.Contains(line, header) {
+				h = i
+				break
+			}
+		}
+		if h < 0 {
+			t.Fatalf("no table with header %q", header)
+		}
+		want := columnEnd(lines[h], column)
+		found := 0
+		for _, line := range lines[h+1:] {
+			if line == "" {
+				break
+			}
+			for name, value := range rows {
+				if strings.Contains(line, name) {
+					found++
+					if got := columnEnd(line, value); got != want {
+						t.Errorf("%s row: %s ends at cell %d, want %d:\n%s\n%s", name, value, got, want, lines[h], line)
+					}
+				}
+			}
+		}
+		if found != len(rows) {
+			t.Errorf("found %d of %d rows under %q", found, len(rows), header)
+		}
+	}
+	checkTable("Total Tokens", "Total Tokens", map[string]string{"数据平台": "1,768,400", "🚀-launch": "282,300"})
+	checkTable("Duration", " Tokens", map[string]string{"aaaaaaaa": "1,626,200", "bbbbbbbb": "282,300", "cccccccc": "105,150"})
+}
+
+// columnEnd returns the cell just past the first occurrence of value in
+// line, or -1 if it does not occur.
+func columnEnd(line, value string) int {
+	i := strings.Index(line, value)
+	if i < 0 {
+		return -1
+	}
+	return displayWidth(line[:i]) + displayWidth(value)
+}
+
+// SummaryLine is a stable interface for scripts: these exact keys, in this
+// order, must not change.
+func TestSummaryLine(t *testing.T) {
+	r := fixtureReport()
+	r.ParseErrors = 2
+	want := "tokens=2050700 cost_usd=3.30 sessions=3 cache_eff=0.94 parse_errors=2"
+	if got := SummaryLine(r); got != want {
+		t.Errorf("SummaryLine = %q, want %q", got, want)
+	}
+	if got, want := SummaryLine(&AggregatedReport{}), "tokens=0 cost_usd=0.00 sessions=0 cache_eff=0.00 parse_errors=0"; got != want {
+		t.Errorf("empty report: SummaryLine = %q, want %q", got, want)
+	}
+	var keys []string
+	for _, field := range strings.Fields(SummaryLine(r)) {
+		k, _, ok := strings.Cut(field, "=")
+		if !ok {
+			t.Fatalf("field %q is not key=value", field)
+		}
+		keys = append(keys, k)
+	}
+	if got := strings.Join(keys, " "); got != "tokens cost_usd sessions cache_eff parse_errors" {
+		t.Errorf("keys = %s", got)
+	}
+}
+
+// Days inserted by --fill-daily-gaps are marked in plain text too, not only
+// by dimming.
+func TestPrintDailyTrendFilledDays(t *testing.T) {
+	r := fixtureReport()
+	filled := newDailySummary("2026-10-03", UsageTotals{})
+	filled.Filled = true
+	r.Daily = []DailySummary{r.Daily[0], r.Daily[1], filled, r.Daily[2]}
+
+	for _, color := range []bool{false, true} {
+		var buf bytes.Buffer
+		printDailyTrend(&Printer{w: &buf, useColors: color}, r)
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if !strings.Contains(line, "2026-10-0") {
+				continue
+			}
+			marked := strings.Contains(line, "(no data)")
+			if want := strings.Contains(line, "2026-10-03"); marked != want {
+				t.Errorf("color=%v: marked=%v, want %v:\n%s", color, marked, want, line)
+			}
+		}
+	}
+}
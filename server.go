@@ -1,22 +1,152 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"embed"
-	"encoding/json"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
 	"net/http"
+	neturl "net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 //go:embed templates/index.html
 var templateFS embed.FS
 
-// ServeReport starts a local HTTP server on the given port.
-// It re-reads and re-aggregates the data on every /api/report request so
-// the dashboard stays live as new Claude Code sessions are written.
-func ServeReport(claudeDir string, opts AggregateOptions, port int) error {
+// ServeOptions controls where and for whom ServeReport listens.
+type ServeOptions struct {
+	Port int
+	Bind string // interface address; empty = 127.0.0.1
+	// StrictPort fails when Port is in use instead of trying the next
+	// maxPortAttempts-1 ports.
+	StrictPort bool
+	// NoBrowser skips opening the dashboard; it is also skipped when
+	// canOpenBrowser reports a headless session.
+	NoBrowser bool
+	// AllowRemote permits a non-loopback Bind without a Token.
+	AllowRemote bool
+	// Token, if set, must accompany every /api/ request: as a Bearer
+	// Authorization header, a ?token= parameter, or the cookie set after
+	// the first ?token= visit. "auto" generates a random one.
+	Token string
+	// CORSOrigins lists the origins (scheme://host[:port], or "*") whose
+	// pages may call the /api/ routes from a browser. Empty sends no CORS
+	// headers, so other sites cannot read the data.
+	CORSOrigins []string
+}
+
+// ServeReport starts a local HTTP server and runs until ctx is cancelled,
+// then shuts down gracefully. It refuses a non-loopback bind
+// address unless sopts allows remote access or sets a token.
+// It re-discovers the data on every /api/report request and re-aggregates
+// whenever the files changed, so the dashboard stays live as new Claude Code
+// sessions are written.
+func ServeReport(ctx context.Context, claudeDirs []string, mergeProjects bool, opts AggregateOptions, sopts ServeOptions) error {
+	bind := sopts.Bind
+	if bind == "" {
+		bind = "127.0.0.1"
+	}
+	if sopts.Token == "auto" {
+		token, err := generateToken()
+		if err != nil {
+			return err
+		}
+		sopts.Token = token
+	}
+	if !isLoopback(bind) && !sopts.AllowRemote && sopts.Token == "" {
+		return fmt.Errorf("refusing to serve usage data on %s: pass --allow-remote or --auth-token to expose it beyond this machine", bind)
+	}
+	origins, err := normalizeOrigins(sopts.CORSOrigins)
+	if err != nil {
+		return err
+	}
+
+	handler, _ := newServeHandler(claudeDirs, mergeProjects, opts, sopts.Token, origins)
+
+	ln, port, err := listenFrom(bind, sopts.Port, sopts.StrictPort)
+	if err != nil {
+		return err
+	}
+	if port != sopts.Port {
+		fmt.Printf("Port %d is in use; using %d instead.\n", sopts.Port, port)
+	}
+	url := "http://" + net.JoinHostPort(reachableHost(bind), strconv.Itoa(port))
+	if sopts.Token != "" {
+		url += "/?token=" + neturl.QueryEscape(sopts.Token)
+	}
+
+	fmt.Printf("Starting web UI at %s\n", url)
+	if sopts.Token != "" {
+		fmt.Printf("API token: %s (send as \"Authorization: Bearer <token>\")\n", sopts.Token)
+	}
+	if !isLoopback(bind) && sopts.Token == "" {
+		fmt.Println("Warning: serving without --auth-token; anyone who can reach this address can read your usage data.")
+	}
+	openIt := !sopts.NoBrowser && canOpenBrowser()
+	if !openIt {
+		fmt.Printf("\n    Open %s in your browser.\n\n", url)
+	}
+	fmt.Println("Press Ctrl+C to stop.")
+
+	// Open browser after a short delay (let the server start first), unless
+	// we're already stopping.
+	if openIt {
+		go func() {
+			select {
+			case <-time.After(300 * time.Millisecond):
+				openBrowser(url)
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	return serve(ctx, ln, handler)
+}
+
+// serve runs handler on ln until ctx is cancelled. Cancelling stops
+// accepting connections and lets in-flight requests finish for up to
+// shutdownTimeout; their contexts are not derived from ctx, so they are not
+// cut short.
+func serve(ctx context.Context, ln net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("\nShutting down…")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
+
+// newServeHandler builds the --serve routes for the given data directories
+// and startup options, wrapped in the token check (when token is set) and
+// the CORS policy. The serverStatus it returns is the one /healthz and
+// /metrics report.
+func newServeHandler(claudeDirs []string, mergeProjects bool, opts AggregateOptions, token string, origins map[string]bool) (http.Handler, *serverStatus) {
 	mux := http.NewServeMux()
 
 	// Serve the web UI
@@ -34,41 +164,845 @@ func ServeReport(claudeDir string, opts AggregateOptions, port int) error {
 		w.Write(data)
 	})
 
-	// Re-compute the report on every request so new sessions are picked up.
+	// Re-discover on every request so new sessions are picked up; the
+	// encoded report is cached per ETag and only rebuilt when the files or
+	// filters change. ?days=N, ?project=, ?model= and ?tz= override the
+	// startup options for one request.
+	cache := newReportCache()
+	status := &serverStatus{started: time.Now()}
 	mux.HandleFunc("/api/report", func(w http.ResponseWriter, r *http.Request) {
-		files, err := DiscoverFiles(claudeDir)
+		opts, err := requestOptions(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files, skipped, err := DiscoverAll(claudeDirs, mergeProjects)
 		if err != nil {
 			http.Error(w, "failed to discover files: "+err.Error(), 500)
 			return
 		}
-		opts.StatsCache = ParseStatsCache(claudeDir)
+		etag := reportETag("report", files, claudeDirs, opts)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		body, ok := cache.get(etag)
+		if !ok {
+			opts.StatsCache = ParseStatsCacheAll(claudeDirs)
+			opts.SkippedPaths = skipped
+			start := time.Now()
+			report := Aggregate(files, opts)
+			// The dashboard filters insights client-side, so all are sent.
+			report.Meta = newReportMeta(claudeDirs, len(files), opts, "info", false)
+			body, err = indentJSON(report, jsonStyle{Full: true}) // the dashboard expects every field
+			if err != nil {
+				http.Error(w, "failed to encode report: "+err.Error(), 500)
+				return
+			}
+			body = append(body, '\n')
+			cache.put(etag, body)
+			status.aggregated(len(files), report, time.Since(start))
+		}
+		w.Write(body)
+	})
+
+	// A page of sessions, for clients that cannot take the whole list at
+	// once: ?offset=&limit=&sort=tokens|cost|recency plus the /api/report
+	// filters. The aggregate behind it is cached per reportETag, so paging
+	// through an unchanged dataset parses it once.
+	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		opts, err := requestOptions(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, err := parseSessionPage(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files, _, err := DiscoverAll(claudeDirs, mergeProjects)
+		if err != nil {
+			http.Error(w, "failed to discover files: "+err.Error(), 500)
+			return
+		}
+		etag := reportETag("sessions", files, claudeDirs, opts)
+		sessions, ok := cache.getSessions(etag)
+		if !ok {
+			opts.SkipClarity = true
+			opts.NoDelta = true
+			start := time.Now()
+			report := Aggregate(files, opts)
+			sessions = report.Sessions
+			cache.putSessions(etag, sessions)
+			status.aggregated(len(files), report, time.Since(start))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		writeJSON(w, page.apply(sessions), true, jsonStyle{Full: true})
+	})
+
+	// Prometheus metrics for the startup options. The aggregate is kept
+	// until the data files change, so frequent scrapes only re-stat files.
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		files, skipped, err := DiscoverAll(claudeDirs, mergeProjects)
+		if err != nil {
+			http.Error(w, "failed to discover files: "+err.Error(), 500)
+			return
+		}
+		etag := reportETag("metrics", files, claudeDirs, opts)
+		report := status.metricsReport(etag)
+		if report == nil {
+			mopts := opts
+			mopts.StatsCache = ParseStatsCacheAll(claudeDirs)
+			mopts.SkippedPaths = skipped
+			mopts.SkipClarity = true // no clarity metrics are exported
+			start := time.Now()
+			report = Aggregate(files, mopts)
+			status.aggregated(len(files), report, time.Since(start))
+			status.setMetricsReport(etag, report)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, report, status.snapshot())
+	})
+
+	// Chart series without the rest of the report; same query filters and
+	// ETag caching as /api/report.
+	chartHandler := func(endpoint string, series func(*AggregatedReport) any) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			opts, err := requestOptions(r, opts)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			files, _, err := DiscoverAll(claudeDirs, mergeProjects)
+			if err != nil {
+				http.Error(w, "failed to discover files: "+err.Error(), 500)
+				return
+			}
+			etag := reportETag(endpoint, files, claudeDirs, opts)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			body, ok := cache.get(etag)
+			if !ok {
+				opts.SkipClarity = true
+				opts.NoDelta = true
+				start := time.Now()
+				report := Aggregate(files, opts)
+				body, err = indentJSON(series(report), jsonStyle{Full: true})
+				if err != nil {
+					http.Error(w, "failed to encode series: "+err.Error(), 500)
+					return
+				}
+				body = append(body, '\n')
+				cache.put(etag, body)
+				status.aggregated(len(files), report, time.Since(start))
+			}
+			w.Write(body)
+		}
+	}
+	mux.HandleFunc("/api/daily", chartHandler("daily", func(r *AggregatedReport) any {
+		points := make([]DailyPoint, len(r.Daily))
+		for i, d := range r.Daily {
+			points[i] = DailyPoint{Date: d.Date, ChartTotals: newChartTotals(d.Totals)}
+		}
+		return map[string]any{"days": r.FilterDays, "series": points}
+	}))
+	mux.HandleFunc("/api/hourly", chartHandler("hourly", func(r *AggregatedReport) any {
+		points := make([]HourlyPoint, len(r.Hourly))
+		for i, h := range r.Hourly {
+			points[i] = HourlyPoint{Hour: h.Hour, ChartTotals: newChartTotals(h.Totals)}
+		}
+		return map[string]any{"series": points}
+	}))
+
+	// One breakdown table as a CSV download (?table=sessions|projects|daily),
+	// with the /api/report filters and the --breakdown column layout.
+	mux.HandleFunc("/api/export.csv", func(w http.ResponseWriter, r *http.Request) {
+		table := r.URL.Query().Get("table")
+		kind, ok := csvTables[table]
+		if !ok {
+			http.Error(w, fmt.Sprintf("invalid table %q: want sessions, projects or daily", table), http.StatusBadRequest)
+			return
+		}
+		opts, err := requestOptions(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files, _, err := DiscoverAll(claudeDirs, mergeProjects)
+		if err != nil {
+			http.Error(w, "failed to discover files: "+err.Error(), 500)
+			return
+		}
+		opts.SkipClarity = true
+		opts.NoDelta = true
+		start := time.Now()
 		report := Aggregate(files, opts)
+		status.aggregated(len(files), report, time.Since(start))
 
+		filename := fmt.Sprintf("token-analyzer-%s-%s.csv", table, time.Now().Format("2006-01-02"))
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		WriteBreakdownCSV(w, report, kind)
+	})
+
+	// Liveness probe for watchdogs: cheap (no parsing), served without the
+	// auth token, and 503 when a data directory can no longer be read.
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h := status.health(claudeDirs)
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		enc := json.NewEncoder(w)
-		enc.SetIndent("", "  ")
-		enc.Encode(report)
+		w.Header().Set("Cache-Control", "no-store")
+		if !h.DataReadable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		writeJSON(w, h, true, jsonStyle{Full: true})
 	})
 
-	addr := fmt.Sprintf(":%d", port)
-	url := fmt.Sprintf("http://localhost:%d", port)
+	// One project's summary, full session list and daily trend; accepts
+	// ?days=N like /api/report.
+	mux.HandleFunc("/api/projects/", func(w http.ResponseWriter, r *http.Request) {
+		slug := strings.TrimPrefix(r.URL.Path, "/api/projects/")
+		if slug == "" || strings.Contains(slug, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		opts, err := requestOptions(r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files, _, err := DiscoverAll(claudeDirs, mergeProjects)
+		if err != nil {
+			http.Error(w, "failed to discover files: "+err.Error(), 500)
+			return
+		}
+		var projectFiles []FileInfo
+		for _, fi := range files {
+			if fi.ProjectSlug == slug {
+				projectFiles = append(projectFiles, fi)
+			}
+		}
 
-	fmt.Printf("Starting web UI at %s\n", url)
-	fmt.Println("Press Ctrl+C to stop.")
+		w.Header().Set("Content-Type", "application/json")
+		if len(projectFiles) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]any{"error": "no project with slug " + slug}, true, jsonStyle{Full: true})
+			return
+		}
+		opts.SkipClarity = true
+		report := Aggregate(projectFiles, opts)
+		writeJSON(w, NewProjectDetail(report, slug), true, jsonStyle{Full: true})
+	})
+
+	// Per-message detail for one session. The ID may be a unique prefix;
+	// an ambiguous one gets 300 Multiple Choices listing the candidates.
+	mux.HandleFunc("/api/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+		if id == "" || strings.Contains(id, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files, _, err := DiscoverAll(claudeDirs, mergeProjects)
+		if err != nil {
+			http.Error(w, "failed to discover files: "+err.Error(), 500)
+			return
+		}
+		matched, candidates := matchSessionFiles(files, id)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case matched != nil:
+			writeJSON(w, BuildSessionDetail(matched), true, jsonStyle{Full: true})
+		case len(candidates) > 0:
+			w.WriteHeader(http.StatusMultipleChoices)
+			writeJSON(w, map[string]any{"error": "ambiguous session ID prefix", "candidates": candidates}, true, jsonStyle{Full: true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]any{"error": "no session matches " + id}, true, jsonStyle{Full: true})
+		}
+	})
+
+	var handler http.Handler = mux
+	if token != "" {
+		handler = requireToken(token, mux)
+	}
+	// Outermost, so preflights (which never carry credentials) are answered
+	// before the token check.
+	handler = allowCORS(origins, handler)
+	return handler, status
+}
+
+// maxPortAttempts is how many consecutive ports --serve tries, starting at
+// --port, before giving up.
+const maxPortAttempts = 10
+
+// listenFrom listens on bind at port or, unless strict, the first free port
+// among the following maxPortAttempts-1. It returns the port it got.
+func listenFrom(bind string, port int, strict bool) (net.Listener, int, error) {
+	attempts := maxPortAttempts
+	if strict || port == 0 {
+		attempts = 1
+	}
+	for p := port; p < port+attempts && p <= 65535; p++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort(bind, strconv.Itoa(p)))
+		if err == nil {
+			return ln, ln.Addr().(*net.TCPAddr).Port, nil
+		}
+		if !isAddrInUse(err) {
+			return nil, 0, err
+		}
+	}
+	if attempts == 1 {
+		return nil, 0, fmt.Errorf("port %d on %s is already in use", port, bind)
+	}
+	return nil, 0, fmt.Errorf("ports %d-%d on %s are all in use; pick another with --port", port, port+attempts-1, bind)
+}
+
+// wsaeaddrinuse is Windows' WSAEADDRINUSE. Go's syscall.EADDRINUSE on
+// Windows is a made-up value that socket errors never match.
+const wsaeaddrinuse = syscall.Errno(10048)
+
+// isAddrInUse reports whether err from net.Listen means the port is taken.
+func isAddrInUse(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == syscall.EADDRINUSE || (runtime.GOOS == "windows" && errno == wsaeaddrinuse)
+}
+
+// shutdownTimeout bounds how long --serve waits for in-flight requests
+// after a stop signal.
+const shutdownTimeout = 5 * time.Second
+
+// csvTables maps /api/export.csv ?table= names to breakdown kinds.
+var csvTables = map[string]string{
+	"sessions": "session",
+	"projects": "project",
+	"daily":    "date",
+}
+
+// ChartTotals is one point of an /api/daily or /api/hourly series, flattened
+// for charting libraries.
+type ChartTotals struct {
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	CacheReadTokens  int64   `json:"cache_read_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
 
-	// Open browser after a short delay (let the server start first)
-	go func() {
-		time.Sleep(300 * time.Millisecond)
-		openBrowser(url)
-	}()
+func newChartTotals(t UsageTotals) ChartTotals {
+	return ChartTotals{
+		InputTokens:      t.InputTokens,
+		OutputTokens:     t.OutputTokens,
+		CacheWriteTokens: t.CacheCreationInputTokens,
+		CacheReadTokens:  t.CacheReadInputTokens,
+		TotalTokens:      t.TotalTokens(),
+		CostUSD:          t.CostUSD,
+	}
+}
 
-	server := &http.Server{
-		Addr:    addr,
-		Handler: mux,
+// DailyPoint is one day of /api/daily.
+type DailyPoint struct {
+	Date string `json:"date"`
+	ChartTotals
+}
+
+// HourlyPoint is one hour of day of /api/hourly.
+type HourlyPoint struct {
+	Hour int `json:"hour"` // 0-23 in the ?tz= zone (UTC by default)
+	ChartTotals
+}
+
+// ProjectDetail is the /api/projects/{slug} response: the ProjectSummary
+// (with every session, sorted by tokens) plus its own daily trend.
+type ProjectDetail struct {
+	*ProjectSummary
+	Daily    []DailySummary `json:"daily"` // scoped to this project; same window as /api/report
+	DateFrom time.Time      `json:"date_from"`
+	DateTo   time.Time      `json:"date_to"`
+}
+
+// NewProjectDetail builds a ProjectDetail from a report aggregated over one
+// project's files. Copies of that slug in several unmerged data directories
+// are folded into the first; a project with no usage in the window comes
+// back with zero totals.
+func NewProjectDetail(r *AggregatedReport, slug string) *ProjectDetail {
+	if len(r.Projects) == 0 {
+		return &ProjectDetail{ProjectSummary: &ProjectSummary{
+			Slug:           slug,
+			Name:           pathBase(slugToPath(slug)),
+			Path:           slugToPath(slug),
+			ModelBreakdown: map[string]*UsageTotals{},
+		}, Daily: r.Daily}
 	}
+	proj := r.Projects[0]
+	for _, other := range r.Projects[1:] {
+		proj.Totals.Merge(other.Totals)
+		proj.SessionCount += other.SessionCount
+		proj.SubagentCount += other.SubagentCount
+		if other.LastActiveTime.After(proj.LastActiveTime) {
+			proj.LastActiveTime = other.LastActiveTime
+		}
+		proj.Sessions = append(proj.Sessions, other.Sessions...)
+		for m, t := range other.ModelBreakdown {
+			if _, ok := proj.ModelBreakdown[m]; !ok {
+				proj.ModelBreakdown[m] = &UsageTotals{}
+			}
+			proj.ModelBreakdown[m].Merge(*t)
+		}
+	}
+	sort.Slice(proj.Sessions, func(i, j int) bool {
+		return proj.Sessions[i].CombinedTokens() > proj.Sessions[j].CombinedTokens()
+	})
+	return &ProjectDetail{ProjectSummary: proj, Daily: r.Daily, DateFrom: r.DateFrom, DateTo: r.DateTo}
+}
 
-	return server.ListenAndServe()
+// requestOptions applies the ?days=, ?project=, ?model= and ?tz= query
+// parameters, where present, to opts. An invalid days or tz value is an
+// error for a 400 response.
+func requestOptions(r *http.Request, opts AggregateOptions) (AggregateOptions, error) {
+	if v := r.URL.Query().Get("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return opts, fmt.Errorf("invalid days %q: want a non-negative integer", v)
+		}
+		opts.Days = days
+	}
+	if v := r.URL.Query().Get("project"); v != "" {
+		opts.Project = v
+	}
+	if v := r.URL.Query().Get("model"); v != "" {
+		opts.Model = v
+	}
+	if v := r.URL.Query().Get("tz"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid tz %q: want an IANA zone name such as Europe/Berlin", v)
+		}
+		opts.Location = loc
+	}
+	return opts, nil
+}
+
+// serverStatus tracks what /healthz and /metrics report about the running
+// server, and holds the aggregate /metrics is served from.
+type serverStatus struct {
+	started time.Time
+
+	mu             sync.Mutex
+	lastAggregated time.Time
+	lastDuration   time.Duration
+	aggregations   int64
+	fileCount      int
+	parseErrors    int
+	lastTimings    PhaseTimings
+
+	metricsETag string
+	metrics     *AggregatedReport
+}
+
+// Health is the /healthz response. LastAggregation is null until the first
+// /api/report has been built.
+type Health struct {
+	Status          string     `json:"status"` // "ok" or "unreadable"
+	UptimeSeconds   int64      `json:"uptime_seconds"`
+	LastAggregation *time.Time `json:"last_aggregation"`
+	FileCount       int        `json:"file_count"`   // as of the last aggregation
+	ParseErrors     int        `json:"parse_errors"` // as of the last aggregation
+	DataReadable    bool       `json:"data_readable"`
+
+	// LastPhaseMillis splits the last aggregation's time into parse,
+	// aggregate and (for /api/report) clarity, in milliseconds.
+	LastPhaseMillis map[string]float64 `json:"last_phase_ms,omitempty"`
+}
+
+// aggregated records a successful aggregation of r over fileCount files
+// that took the given time.
+func (s *serverStatus) aggregated(fileCount int, r *AggregatedReport, took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastAggregated = time.Now()
+	s.lastDuration = took
+	s.aggregations++
+	s.fileCount = fileCount
+	s.parseErrors = r.ParseErrors
+	s.lastTimings = r.Timings
+}
+
+// metricsReport returns the stored /metrics aggregate if it was built for
+// etag, else nil.
+func (s *serverStatus) metricsReport(etag string) *AggregatedReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.metricsETag != etag {
+		return nil
+	}
+	return s.metrics
+}
+
+func (s *serverStatus) setMetricsReport(etag string, r *AggregatedReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metricsETag, s.metrics = etag, r
+}
+
+// snapshot copies the process-level figures for /metrics.
+func (s *serverStatus) snapshot() processMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return processMetrics{
+		Uptime:          time.Since(s.started),
+		Aggregations:    s.aggregations,
+		LastDuration:    s.lastDuration,
+		LastAggregation: s.lastAggregated,
+	}
+}
+
+// health snapshots the status and checks that each data directory's
+// projects folder can still be listed.
+func (s *serverStatus) health(claudeDirs []string) Health {
+	s.mu.Lock()
+	h := Health{
+		Status:        "ok",
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+		FileCount:     s.fileCount,
+		ParseErrors:   s.parseErrors,
+		DataReadable:  true,
+	}
+	if !s.lastAggregated.IsZero() {
+		t := s.lastAggregated
+		h.LastAggregation = &t
+		h.LastPhaseMillis = s.lastTimings.Millis()
+	}
+	s.mu.Unlock()
+
+	for _, dir := range claudeDirs {
+		f, err := os.Open(filepath.Join(dir, "projects"))
+		if err == nil {
+			_, err = f.Readdirnames(1)
+			f.Close()
+		}
+		if err != nil && err != io.EOF {
+			h.DataReadable = false
+			h.Status = "unreadable"
+		}
+	}
+	return h
+}
+
+// maxCachedReports bounds the /api/report cache; each distinct query string
+// holds one entry.
+const maxCachedReports = 16
+
+// reportCache holds encoded /api/report bodies, and the session lists
+// /api/sessions pages through, keyed by ETag.
+type reportCache struct {
+	mu       sync.Mutex
+	entries  map[string][]byte
+	sessions map[string][]*SessionSummary
+}
+
+func newReportCache() *reportCache {
+	return &reportCache{entries: make(map[string][]byte), sessions: make(map[string][]*SessionSummary)}
+}
+
+func (c *reportCache) get(etag string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, ok := c.entries[etag]
+	return body, ok
+}
+
+// put stores body, dropping every entry first once the cache is full; stale
+// fingerprints are never requested again, so there is nothing worth keeping.
+func (c *reportCache) put(etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedReports {
+		c.entries = make(map[string][]byte)
+	}
+	c.entries[etag] = body
+}
+
+// getSessions returns the sessions stored for etag, sorted by tokens. The
+// slice is shared; callers must not reorder it.
+func (c *reportCache) getSessions(etag string) ([]*SessionSummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sessions, ok := c.sessions[etag]
+	return sessions, ok
+}
+
+func (c *reportCache) putSessions(etag string, sessions []*SessionSummary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sessions) >= maxCachedReports {
+		c.sessions = make(map[string][]*SessionSummary)
+	}
+	c.sessions[etag] = sessions
+}
+
+// Paging limits for /api/sessions.
+const (
+	defaultSessionPage = 50
+	maxSessionPage     = 500
+)
+
+// sessionSorts are the /api/sessions ?sort= keys, each ordering sessions
+// largest (or most recent) first.
+var sessionSorts = map[string]func(a, b *SessionSummary) bool{
+	"tokens": func(a, b *SessionSummary) bool { return a.CombinedTokens() > b.CombinedTokens() },
+	"cost": func(a, b *SessionSummary) bool {
+		return a.Totals.CostUSD+a.SubagentTotals.CostUSD > b.Totals.CostUSD+b.SubagentTotals.CostUSD
+	},
+	"recency": func(a, b *SessionSummary) bool { return a.EndTime.After(b.EndTime) },
+}
+
+// sessionPage is one /api/sessions request's paging and order.
+type sessionPage struct {
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Sort   string `json:"sort"`
+}
+
+// SessionPage is the /api/sessions response. Total counts every session
+// matching the filters, not just this page.
+type SessionPage struct {
+	sessionPage
+	Total    int               `json:"total"`
+	Sessions []*SessionSummary `json:"sessions"`
+}
+
+// parseSessionPage reads ?offset=, ?limit= (capped at maxSessionPage) and
+// ?sort= from r.
+func parseSessionPage(r *http.Request) (sessionPage, error) {
+	q := r.URL.Query()
+	page := sessionPage{Limit: defaultSessionPage, Sort: "tokens"}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page, fmt.Errorf("invalid offset %q: want a non-negative integer", v)
+		}
+		page.Offset = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return page, fmt.Errorf("invalid limit %q: want a positive integer", v)
+		}
+		page.Limit = min(n, maxSessionPage)
+	}
+	if v := q.Get("sort"); v != "" {
+		if sessionSorts[v] == nil {
+			return page, fmt.Errorf("invalid sort %q: want tokens, cost or recency", v)
+		}
+		page.Sort = v
+	}
+	return page, nil
+}
+
+// apply sorts a copy of sessions and cuts out the page.
+func (p sessionPage) apply(sessions []*SessionSummary) SessionPage {
+	sorted := make([]*SessionSummary, len(sessions))
+	copy(sorted, sessions)
+	less := sessionSorts[p.Sort]
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	from := min(p.Offset, len(sorted))
+	to := min(from+p.Limit, len(sorted))
+	return SessionPage{sessionPage: p, Total: len(sorted), Sessions: sorted[from:to]}
+}
+
+// reportETag fingerprints the inputs of one response from endpoint: the
+// file count, total size and newest mtime of the discovered files and of
+// stats-cache.json, the request's filters, and today's date (day windows
+// and zero-filled trends move at midnight even when no file changes).
+func reportETag(endpoint string, files []FileInfo, claudeDirs []string, opts AggregateOptions) string {
+	var size int64
+	var newest time.Time
+	stat := func(path string) {
+		if st, err := os.Stat(path); err == nil {
+			size += st.Size()
+			if st.ModTime().After(newest) {
+				newest = st.ModTime()
+			}
+		}
+	}
+	for _, fi := range files {
+		stat(fi.Path)
+	}
+	for _, dir := range claudeDirs {
+		stat(filepath.Join(dir, "stats-cache.json"))
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%d|%d|%d|%d|%s|%s|%s|%s|%s",
+		endpoint, len(files), size, newest.UnixNano(), opts.Days, opts.Project, opts.Model,
+		loc.String(), time.Now().In(loc).Format("2006-01-02"), toolVersion())
+	return fmt.Sprintf(`"%x"`, h.Sum64())
+}
+
+// tokenCookie carries the --auth-token after a ?token= visit so the
+// dashboard's own API requests are authorized.
+const tokenCookie = "token_analyzer_auth"
+
+// requireToken rejects /api/ and /metrics requests that don't present
+// token. The page itself is served to anyone so it can ask for the token; a
+// valid ?token= on any path sets the cookie.
+func requireToken(token string, next http.Handler) http.Handler {
+	matches := func(got string) bool {
+		return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("token"); matches(q) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    q,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+			next.ServeHTTP(w, r)
+			return
+		}
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && matches(bearer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if c, err := r.Cookie(tokenCookie); err == nil && matches(c.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "unauthorized: pass the --auth-token as ?token= or a Bearer header", http.StatusUnauthorized)
+	})
+}
+
+// normalizeOrigins validates --cors-origin values and strips a trailing
+// slash, since browsers send Origin without one.
+func normalizeOrigins(origins []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		o = strings.TrimSuffix(strings.TrimSpace(o), "/")
+		if o != "*" {
+			u, err := neturl.Parse(o)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+				return nil, fmt.Errorf("invalid --cors-origin %q: want scheme://host[:port] or *", o)
+			}
+		}
+		set[o] = true
+	}
+	return set, nil
+}
+
+// corsMaxAge is how long browsers may cache a preflight answer, in seconds.
+const corsMaxAge = "600"
+
+// allowCORS adds CORS headers to /api/ responses for requests from one of
+// origins ("*" allows any) and answers their OPTIONS preflights. Requests
+// from other origins (all of them when origins is empty) get no CORS
+// headers, so browsers keep the response from the calling page; their
+// preflights are refused with 403.
+func allowCORS(origins map[string]bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		allowed := origins[origin] || origins["*"]
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !allowed {
+			if preflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, If-None-Match")
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// generateToken returns a random 128-bit hex token for --auth-token auto.
+func generateToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating auth token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isLoopback reports whether bind only accepts connections from this machine.
+func isLoopback(bind string) bool {
+	if bind == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(bind)
+	return ip != nil && ip.IsLoopback()
+}
+
+// reachableHost returns the host to print for a server bound to bind. A
+// wildcard bind is reported as this machine's first non-loopback IPv4
+// address, falling back to the hostname.
+func reachableHost(bind string) string {
+	if bind == "localhost" || (bind != "0.0.0.0" && bind != "::") {
+		return bind
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok && !ipn.IP.IsLoopback() && ipn.IP.To4() != nil {
+				return ipn.IP.String()
+			}
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "localhost"
+}
+
+// canOpenBrowser reports whether a browser can plausibly be launched: not
+// when stdout is not a terminal (piped, a service, a container log), nor on
+// Linux without a graphical session, e.g. over SSH.
+func canOpenBrowser() bool {
+	if !isTerminal() {
+		return false
+	}
+	if runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return false
+	}
+	return true
 }
 
 func openBrowser(url string) {
@@ -83,5 +1017,7 @@ func openBrowser(url string) {
 	default:
 		return
 	}
-	_ = cmd.Start()
+	if cmd.Start() == nil {
+		go cmd.Wait() // reap it; xdg-open can linger
+	}
 }
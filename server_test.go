@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestHandler writes two sessions under a temporary data directory and
+// returns the --serve handler for it.
+func newTestHandler(t *testing.T, token string, origins ...string) (dir string, h http.Handler, status *serverStatus) {
+	t.Helper()
+	dir = t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	twoPromptSession(t, dir, "-work-api", testSession, start)
+	twoPromptSession(t, dir, "-work-web", testOther, start.Add(24*time.Hour))
+	allowed, err := normalizeOrigins(origins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, status = newServeHandler([]string{dir}, false, AggregateOptions{}, token, allowed)
+	return dir, h, status
+}
+
+// get sends a GET for target to h with the given headers set.
+func get(h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequestOptions(t *testing.T) {
+	base := AggregateOptions{Days: 30, Project: "api"}
+	tests := []struct {
+		query   string
+		days    int
+		project string
+		model   string
+		tz      string
+		wantErr bool
+	}{
+		{"", 30, "api", "", "", false},
+		{"days=7&project=web&model=opus", 7, "web", "opus", "", false},
+		{"days=0", 0, "api", "", "", false},
+		{"tz=Europe/Berlin", 30, "api", "", "Europe/Berlin", false},
+		{"tz=UTC", 30, "api", "", "UTC", false},
+		{"days=-1", 0, "", "", "", true},
+		{"days=week", 0, "", "", "", true},
+		{"tz=Mars/Olympus", 0, "", "", "", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/report?"+tt.query, nil)
+		got, err := requestOptions(r, base)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: no error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		tz := ""
+		if got.Location != nil {
+			tz = got.Location.String()
+		}
+		if got.Days != tt.days || got.Project != tt.project || got.Model != tt.model || tz != tt.tz {
+			t.Errorf("%q: days=%d project=%q model=%q tz=%q; want %d %q %q %q",
+				tt.query, got.Days, got.Project, got.Model, tz, tt.days, tt.project, tt.model, tt.tz)
+		}
+	}
+}
+
+// Unchanged data is aggregated once: repeat requests reuse the cached body
+// and a matching If-None-Match gets 304.
+func TestServeReportCache(t *testing.T) {
+	dir, h, status := newTestHandler(t, "")
+	aggregations := func() int64 { return status.snapshot().Aggregations }
+
+	first := get(h, "/api/report")
+	if first.Code != http.StatusOK {
+		t.Fatalf("GET /api/report = %d: %s", first.Code, first.Body)
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" || first.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("headers = %v, want an ETag and Cache-Control: no-cache", first.Header())
+	}
+	var report AggregatedReport
+	if err := json.Unmarshal(first.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Meta == nil || report.Meta.SchemaVersion != schemaVersion || len(report.Sessions) != 2 {
+		t.Errorf("report: meta %+v, %d sessions", report.Meta, len(report.Sessions))
+	}
+
+	second := get(h, "/api/report")
+	if second.Code != http.StatusOK || second.Body.String() != first.Body.String() || second.Header().Get("ETag") != etag {
+		t.Errorf("second GET = %d with ETag %q, want the same 200 response", second.Code, second.Header().Get("ETag"))
+	}
+	if n := aggregations(); n != 1 {
+		t.Errorf("aggregated %d times for two requests, want 1", n)
+	}
+
+	notModified := get(h, "/api/report", "If-None-Match", etag)
+	if notModified.Code != http.StatusNotModified || notModified.Body.Len() != 0 {
+		t.Errorf("If-None-Match GET = %d with %d bytes, want an empty 304", notModified.Code, notModified.Body.Len())
+	}
+
+	// Different filters and new data each need a fresh aggregate.
+	if rec := get(h, "/api/report?model=opus"); rec.Header().Get("ETag") == etag {
+		t.Error("?model= did not change the ETag")
+	}
+	twoPromptSession(t, dir, "-work-api", "11111111-2222-3333-4444-555555555555", time.Date(2026, 10, 3, 9, 0, 0, 0, time.UTC))
+	changed := get(h, "/api/report", "If-None-Match", etag)
+	if changed.Code != http.StatusOK || changed.Header().Get("ETag") == etag {
+		t.Errorf("after a new session: %d with ETag %q, want 200 with a new ETag", changed.Code, changed.Header().Get("ETag"))
+	}
+	if n := aggregations(); n != 3 {
+		t.Errorf("aggregated %d times, want 3", n)
+	}
+}
+
+func TestParseSessionPage(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    sessionPage
+		wantErr string
+	}{
+		{"", sessionPage{Limit: defaultSessionPage, Sort: "tokens"}, ""},
+		{"offset=100&limit=20&sort=cost", sessionPage{Offset: 100, Limit: 20, Sort: "cost"}, ""},
+		{"limit=100000", sessionPage{Limit: maxSessionPage, Sort: "tokens"}, ""},
+		{"sort=recency", sessionPage{Limit: defaultSessionPage, Sort: "recency"}, ""},
+		{"offset=-1", sessionPage{}, "invalid offset"},
+		{"offset=x", sessionPage{}, "invalid offset"},
+		{"limit=0", sessionPage{}, "invalid limit"},
+		{"sort=name", sessionPage{}, "want tokens, cost or recency"},
+	}
+	for _, tt := range tests {
+		got, err := parseSessionPage(httptest.NewRequest("GET", "/api/sessions?"+tt.query, nil))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSessionPage(%q) error = %v, want %q", tt.query, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSessionPage(%q) = %+v, %v; want %+v", tt.query, got, err, tt.want)
+		}
+	}
+}
+
+func TestSessionPageApply(t *testing.T) {
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sessions := []*SessionSummary{
+		{SessionID: "a", Totals: UsageTotals{InputTokens: 300, CostUSD: 1}, EndTime: day.Add(24 * time.Hour)},
+		{SessionID: "b", Totals: UsageTotals{InputTokens: 100, CostUSD: 1}, SubagentTotals: UsageTotals{CostUSD: 2}, EndTime: day},
+		{SessionID: "c", Totals: UsageTotals{InputTokens: 100, CostUSD: 2}, SubagentTotals: UsageTotals{InputTokens: 100}, EndTime: day.Add(48 * time.Hour)},
+	}
+	ids := func(p SessionPage) string {
+		var out []string
+		for _, s := range p.Sessions {
+			out = append(out, s.SessionID)
+		}
+		return strings.Join(out, ",")
+	}
+	tests := []struct {
+		page sessionPage
+		want string
+	}{
+		{sessionPage{Limit: 50, Sort: "tokens"}, "a,c,b"},
+		{sessionPage{Limit: 50, Sort: "cost"}, "b,c,a"},
+		{sessionPage{Limit: 50, Sort: "recency"}, "c,a,b"},
+		{sessionPage{Offset: 1, Limit: 1, Sort: "tokens"}, "c"},
+		{sessionPage{Offset: 2, Limit: 5, Sort: "tokens"}, "b"},
+		{sessionPage{Offset: 10, Limit: 5, Sort: "tokens"}, ""},
+	}
+	for _, tt := range tests {
+		got := tt.page.apply(sessions)
+		if ids(got) != tt.want || got.Total != len(sessions) {
+			t.Errorf("%+v: sessions %q, total %d; want %q, %d", tt.page, ids(got), got.Total, tt.want, len(sessions))
+		}
+	}
+	if sessions[0].SessionID != "a" || sessions[1].SessionID != "b" {
+		t.Error("apply reordered the caller's slice")
+	}
+}
+
+func TestServeSessions(t *testing.T) {
+	_, h, _ := newTestHandler(t, "")
+
+	page := func(target string) SessionPage {
+		t.Helper()
+		rec := get(h, target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d: %s", target, rec.Code, rec.Body)
+		}
+		var p SessionPage
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		return p
+	}
+
+	if p := page("/api/sessions"); p.Total != 2 || len(p.Sessions) != 2 || p.Limit != defaultSessionPage || p.Sort != "tokens" {
+		t.Errorf("default page: total %d, %d sessions, limit %d, sort %q", p.Total, len(p.Sessions), p.Limit, p.Sort)
+	}
+	if p := page("/api/sessions?limit=1&sort=recency"); p.Total != 2 || len(p.Sessions) != 1 || p.Sessions[0].SessionID != testOther {
+		t.Errorf("limit=1&sort=recency: total %d, %d sessions, want 2 and the later session %s first", p.Total, len(p.Sessions), testOther)
+	}
+	if p := page("/api/sessions?offset=1&limit=1&sort=recency"); len(p.Sessions) != 1 || p.Sessions[0].SessionID != testSession || p.Offset != 1 {
+		t.Errorf("offset=1: %d sessions, offset %d, want %s", len(p.Sessions), p.Offset, testSession)
+	}
+	if p := page("/api/sessions?limit=9999"); p.Limit != maxSessionPage {
+		t.Errorf("limit=9999: limit %d, want %d", p.Limit, maxSessionPage)
+	}
+	if p := page("/api/sessions?project=web"); p.Total != 1 || p.Sessions[0].SessionID != testOther {
+		t.Errorf("project=web: total %d, want only %s", p.Total, testOther)
+	}
+
+	rec := get(h, "/api/sessions?sort=name")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "tokens, cost or recency") {
+		t.Errorf("sort=name: %d %q, want 400 listing the sort keys", rec.Code, rec.Body)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	const token = "s3cret"
+	_, h, _ := newTestHandler(t, token)
+	cookie := tokenCookie + "=" + token
+
+	tests := []struct {
+		name   string
+		target string
+		header []string
+		want   int
+	}{
+		{"api without token", "/api/report", nil, http.StatusUnauthorized},
+		{"metrics without token", "/metrics", nil, http.StatusUnauthorized},
+		{"session without token", "/api/sessions/" + testSession, nil, http.StatusUnauthorized},
+		{"wrong query token", "/api/report?token=guess", nil, http.StatusUnauthorized},
+		{"wrong bearer", "/api/report", []string{"Authorization", "Bearer guess"}, http.StatusUnauthorized},
+		{"token without Bearer", "/api/report", []string{"Authorization", token}, http.StatusUnauthorized},
+		{"wrong cookie", "/api/report", []string{"Cookie", tokenCookie + "=guess"}, http.StatusUnauthorized},
+		{"query token", "/api/report?token=" + token, nil, http.StatusOK},
+		{"bearer", "/api/report", []string{"Authorization", "Bearer " + token}, http.StatusOK},
+		{"cookie", "/api/report", []string{"Cookie", cookie}, http.StatusOK},
+		{"metrics with bearer", "/metrics", []string{"Authorization", "Bearer " + token}, http.StatusOK},
+		{"page", "/", nil, http.StatusOK},
+		{"healthz", "/healthz", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := get(h, tt.target, tt.header...); rec.Code != tt.want {
+			t.Errorf("%s: GET %s = %d, want %d", tt.name, tt.target, rec.Code, tt.want)
+		}
+	}
+
+	// A valid ?token= sets the cookie the dashboard's own requests use.
+	rec := get(h, "/?token="+token)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != tokenCookie || cookies[0].Value != token || !cookies[0].HttpOnly {
+		t.Errorf("?token= cookies = %v, want an HttpOnly %s", cookies, tokenCookie)
+	}
+	if rec := get(h, "/?token=guess"); len(rec.Result().Cookies()) != 0 {
+		t.Error("a wrong ?token= set a cookie")
+	}
+}
+
+func TestAllowCORS(t *testing.T) {
+	const app = "http://localhost:5173"
+	_, closed, _ := newTestHandler(t, "")
+	_, open, _ := newTestHandler(t, "", app+"/")
+	_, wildcard, _ := newTestHandler(t, "", "*")
+
+	preflight := func(h http.Handler, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/report", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		rec    *httptest.ResponseRecorder
+		code   int
+		origin string // want Access-Control-Allow-Origin
+	}{
+		{"default", get(closed, "/api/report", "Origin", app), http.StatusOK, ""},
+		{"allowed origin", get(open, "/api/report", "Origin", app), http.StatusOK, app},
+		{"other origin", get(open, "/api/report", "Origin", "http://evil.example"), http.StatusOK, ""},
+		{"wildcard", get(wildcard, "/api/report", "Origin", "http://evil.example"), http.StatusOK, "http://evil.example"},
+		{"no Origin", get(open, "/api/report"), http.StatusOK, ""},
+		{"non-api path", get(open, "/healthz", "Origin", app), http.StatusOK, ""},
+		{"preflight allowed", preflight(open, app), http.StatusNoContent, app},
+		{"preflight other origin", preflight(open, "http://evil.example"), http.StatusForbidden, ""},
+		{"preflight default", preflight(closed, app), http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		if tt.rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, tt.rec.Code, tt.code)
+		}
+		if got := tt.rec.Header().Get("Access-Control-Allow-Origin"); got != tt.origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.origin)
+		}
+	}
+
+	if got := get(open, "/api/report", "Origin", "http://evil.example").Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+	rec := preflight(open, app)
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Authorization") {
+		t.Errorf("preflight Access-Control-Allow-Headers = %q, want Authorization listed", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != corsMaxAge {
+		t.Errorf("preflight Access-Control-Max-Age = %q, want %s", got, corsMaxAge)
+	}
+	if got := get(open, "/api/report", "Origin", app).Header().Get("Access-Control-Expose-Headers"); got != "ETag" {
+		t.Errorf("Access-Control-Expose-Headers = %q, want ETag", got)
+	}
+}
+
+func TestNormalizeOrigins(t *testing.T) {
+	got, err := normalizeOrigins([]string{" https://app.example.com/ ", "http://localhost:3000", "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []string{"https://app.example.com", "http://localhost:3000", "*"} {
+		if !got[o] {
+			t.Errorf("normalizeOrigins result %v is missing %q", got, o)
+		}
+	}
+	for _, bad := range []string{"app.example.com", "ftp://app.example.com", "https://", "https://app.example.com/path", "https://app.example.com?x=1"} {
+		if _, err := normalizeOrigins([]string{bad}); err == nil {
+			t.Errorf("normalizeOrigins(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestIsAddrInUse(t *testing.T) {
+	wrap := func(err error) error {
+		return &net.OpError{Op: "listen", Net: "tcp", Err: os.NewSyscallError("bind", err)}
+	}
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{wrap(syscall.EADDRINUSE), true},
+		{wrap(wsaeaddrinuse), runtime.GOOS == "windows"},
+		{wrap(syscall.EACCES), false},
+		{errors.New("address already in use"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isAddrInUse(tt.err); got != tt.want {
+			t.Errorf("isAddrInUse(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestListenFromPortInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	port := taken.Addr().(*net.TCPAddr).Port
+	if port+maxPortAttempts > 65535 {
+		t.Skipf("ephemeral port %d too close to the top of the range", port)
+	}
+
+	if _, _, err := listenFrom("127.0.0.1", port, true); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("strict listenFrom on a taken port: err = %v, want \"already in use\"", err)
+	}
+
+	ln, got, err := listenFrom("127.0.0.1", port, false)
+	if err != nil {
+		t.Fatalf("listenFrom on a taken port: %v", err)
+	}
+	defer ln.Close()
+	if got <= port || got >= port+maxPortAttempts {
+		t.Errorf("listenFrom(%d) got port %d, want one in %d-%d", port, got, port+1, port+maxPortAttempts-1)
+	}
+}
+
+// Cancelling the context lets a request that is already running finish, then
+// closes the listener.
+func TestServeShutdownCompletesInFlight(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-time.After(300 * time.Millisecond):
+			io.WriteString(w, "done")
+		case <-r.Context().Done():
+			http.Error(w, "cancelled", http.StatusServiceUnavailable)
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, ln, handler) }()
+
+	url := "http://" + ln.Addr().String() + "/slow"
+	type result struct {
+		body string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		done <- result{string(b), err}
+	}()
+
+	<-started
+	cancel()
+	res := <-done
+	if res.err != nil || res.body != "done" {
+		t.Errorf("in-flight request = %q, %v; want \"done\"", res.body, res.err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve returned %v", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatal("serve did not return after shutdown")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDetailMessages caps the per-message list of a SessionDetail so a
+// pathological session can't produce a multi-megabyte response.
+const maxDetailMessages = 2000
+
+// maxTitleLen caps a title taken from the first prompt, in runes.
+const maxTitleLen = 120
+
+// SessionDetail is the /api/sessions/{id} view of one session.
+type SessionDetail struct {
+	SessionID       string           `json:"session_id"`
+	ProjectName     string           `json:"project_name"`
+	Title           string           `json:"title"` // last "summary" record, else the first prompt
+	StartTime       time.Time        `json:"start_time"`
+	EndTime         time.Time        `json:"end_time"`
+	DurationSeconds int64            `json:"duration_seconds"`
+	Totals          UsageTotals      `json:"totals"`          // main conversation only
+	SubagentTotals  UsageTotals      `json:"subagent_totals"` // all subagent files
+	Subagents       []SubagentDetail `json:"subagents"`       // sorted by cost desc
+	MessageCount    int              `json:"message_count"`   // every usage record, main and subagent
+	Messages        []MessageUsage   `json:"messages"`        // sorted by time; at most maxDetailMessages
+	Truncated       bool             `json:"truncated"`       // Messages was cut to maxDetailMessages
+}
+
+// SubagentDetail totals one subagent file of a session.
+type SubagentDetail struct {
+	AgentID string      `json:"agent_id"`
+	Totals  UsageTotals `json:"totals"`
+}
+
+// MessageUsage is the usage of a single assistant message.
+type MessageUsage struct {
+	UUID                     string    `json:"uuid"`
+	Timestamp                time.Time `json:"timestamp"`
+	Model                    string    `json:"model"`
+	AgentID                  string    `json:"agent_id,omitempty"` // empty for the main conversation
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens"`
+	CostUSD                  float64   `json:"cost_usd"`
+}
+
+// matchSessionFiles returns the files of the session whose ID is id or,
+// failing an exact match, starts with id. When the prefix fits several
+// sessions no files are returned and candidates lists their IDs, sorted.
+func matchSessionFiles(files []FileInfo, id string) (matched []FileInfo, candidates []string) {
+	byID := make(map[string][]FileInfo)
+	for _, fi := range files {
+		if strings.HasPrefix(fi.SessionID, id) {
+			byID[fi.SessionID] = append(byID[fi.SessionID], fi)
+		}
+	}
+	if exact, ok := byID[id]; ok {
+		return exact, nil
+	}
+	if len(byID) == 1 {
+		for _, fs := range byID {
+			return fs, nil
+		}
+	}
+	for sid := range byID {
+		candidates = append(candidates, sid)
+	}
+	sort.Strings(candidates)
+	return nil, candidates
+}
+
+// BuildSessionDetail parses the files of one session (as returned by
+// matchSessionFiles) into a SessionDetail.
+func BuildSessionDetail(files []FileInfo) *SessionDetail {
+	d := &SessionDetail{SessionID: files[0].SessionID}
+	agents := make(map[string]*SubagentDetail)
+	var cwd, firstPrompt string
+
+	for _, fi := range files {
+		var records []MessageRecord
+		if fi.Kind == KindSession {
+			// The main file also carries the title, the first prompt and the
+			// cwd, so it is read once for everything and its usage records
+			// picked out the way ParseFile would.
+			all, _ := ParseFileAllRecords(fi.Path)
+			for _, rec := range all {
+				if rec.Type == "summary" && rec.Summary != "" {
+					d.Title = rec.Summary
+				}
+				if firstPrompt == "" && isRealUserMessage(rec) {
+					firstPrompt = extractText(rec.Message.Content)
+				}
+				if cwd == "" {
+					cwd = rec.CWD
+				}
+				if rec.Type == "assistant" && !rec.Message.Usage.IsZero() {
+					records = append(records, rec)
+				}
+			}
+		} else {
+			records, _, _ = ParseFile(fi.Path, nil)
+		}
+
+		for _, rec := range records {
+			usage := rec.Message.Usage
+			cost := ComputeCostBreakdown(rec.Message.Model, usage)
+			if fi.Kind == KindSubagent {
+				d.SubagentTotals.Add(usage, cost)
+				sa, ok := agents[fi.AgentID]
+				if !ok {
+					sa = &SubagentDetail{AgentID: fi.AgentID}
+					agents[fi.AgentID] = sa
+				}
+				sa.Totals.Add(usage, cost)
+			} else {
+				d.Totals.Add(usage, cost)
+			}
+			if !rec.Timestamp.IsZero() {
+				if d.StartTime.IsZero() || rec.Timestamp.Before(d.StartTime) {
+					d.StartTime = rec.Timestamp
+				}
+				if rec.Timestamp.After(d.EndTime) {
+					d.EndTime = rec.Timestamp
+				}
+			}
+			d.Messages = append(d.Messages, MessageUsage{
+				UUID:                     rec.UUID,
+				Timestamp:                rec.Timestamp,
+				Model:                    rec.Message.Model,
+				AgentID:                  fi.AgentID,
+				InputTokens:              usage.InputTokens,
+				OutputTokens:             usage.OutputTokens,
+				CacheCreationInputTokens: usage.CacheCreationInputTokens,
+				CacheReadInputTokens:     usage.CacheReadInputTokens,
+				CostUSD:                  cost.Total(),
+			})
+		}
+	}
+
+	if cwd == "" {
+		cwd = slugToPath(files[0].ProjectSlug)
+	}
+	d.ProjectName = pathBase(cwd)
+	if d.Title == "" {
+		d.Title = firstLine(firstPrompt, maxTitleLen)
+	}
+	d.DurationSeconds = int64(d.EndTime.Sub(d.StartTime).Seconds())
+
+	for _, sa := range agents {
+		d.Subagents = append(d.Subagents, *sa)
+	}
+	sort.Slice(d.Subagents, func(i, j int) bool {
+		return d.Subagents[i].Totals.CostUSD > d.Subagents[j].Totals.CostUSD
+	})
+
+	sort.SliceStable(d.Messages, func(i, j int) bool {
+		return d.Messages[i].Timestamp.Before(d.Messages[j].Timestamp)
+	})
+	d.MessageCount = len(d.Messages)
+	if len(d.Messages) > maxDetailMessages {
+		d.Messages = d.Messages[:maxDetailMessages]
+		d.Truncated = true
+	}
+	return d
+}
+
+// firstLine returns the first non-blank line of s, cut to n runes.
+func firstLine(s string, n int) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if r := []rune(line); len(r) > n {
+				return string(r[:n-1]) + "…"
+			}
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildSessionDetail(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	u := TokenUsage{InputTokens: 100, OutputTokens: 200}
+	reply := assistantText(t, testSession, ts(start.Add(time.Minute)), "claude-sonnet-4-5", "Looking.", u)
+	main := writeSession(t, dir, "-work-api", testSession,
+		userText(t, testSession, ts(start), "Fix the flaky parser test\nIt fails on CI only."),
+		reply,
+		reply, // written twice; counted once
+		assistantText(t, testSession, ts(start.Add(2*time.Minute)), "claude-sonnet-4-5", "", TokenUsage{}),
+		assistantText(t, testSession, ts(start.Add(3*time.Minute)), "claude-sonnet-4-5", "Fixed.", u),
+	)
+	sub := FileInfo{
+		Path:        filepath.Join(dir, "projects", "-work-api", testSession, "subagents", "agent-a1.jsonl"),
+		Kind:        KindSubagent,
+		ProjectSlug: "-work-api",
+		SessionID:   testSession,
+		AgentID:     "a1",
+	}
+	if err := os.MkdirAll(filepath.Dir(sub.Path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	subLine := assistantText(t, testSession, ts(start.Add(5*time.Minute)), "claude-sonnet-4-5", "Searched.", u)
+	if err := os.WriteFile(sub.Path, []byte(subLine+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := BuildSessionDetail([]FileInfo{main, sub})
+	if d.Title != "Fix the flaky parser test" || d.ProjectName != "api" {
+		t.Errorf("Title, ProjectName = %q, %q; want the first prompt line and api", d.Title, d.ProjectName)
+	}
+	if d.Totals.InputTokens != 200 || d.Totals.MessageCount != 2 {
+		t.Errorf("Totals = %+v, want 2 messages and 200 input tokens", d.Totals)
+	}
+	if d.SubagentTotals.InputTokens != 100 || len(d.Subagents) != 1 || d.Subagents[0].AgentID != "a1" {
+		t.Errorf("SubagentTotals = %+v, Subagents = %+v; want one subagent a1 with 100 input tokens", d.SubagentTotals, d.Subagents)
+	}
+	if d.MessageCount != 3 || len(d.Messages) != 3 || d.Messages[2].AgentID != "a1" {
+		t.Errorf("Messages = %+v, want 3 sorted by time, the subagent's last", d.Messages)
+	}
+	if !d.StartTime.Equal(start.Add(time.Minute)) || d.DurationSeconds != 240 {
+		t.Errorf("StartTime, DurationSeconds = %v, %d; want %v, 240", d.StartTime, d.DurationSeconds, start.Add(time.Minute))
+	}
+
+	// A summary record, wherever it appears, wins over the first prompt.
+	summary := `{"type":"summary","summary":"Flaky parser test","leafUuid":"x"}`
+	withSummary := writeSession(t, dir, "-work-api", testOther,
+		userText(t, testOther, ts(start), "Fix the flaky parser test"),
+		summary,
+	)
+	if d := BuildSessionDetail([]FileInfo{withSummary}); d.Title != "Flaky parser test" {
+		t.Errorf("Title = %q, want the summary", d.Title)
+	}
+}
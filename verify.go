@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// verifyTolerance is the relative difference above which Verify reports a
+// value as a discrepancy.
+const verifyTolerance = 0.001
+
+// Verify compares two reports built for the same date range and returns one
+// line per value that differs by more than verifyTolerance: grand totals,
+// session count, and the totals per model, project and day. Nil means the
+// reports agree.
+func Verify(r1, r2 *AggregatedReport) []string {
+	var out []string
+	check := func(field string, a, b float64) {
+		if a == b {
+			return
+		}
+		rel := math.Abs(a-b) / math.Max(math.Abs(a), math.Abs(b))
+		if rel > verifyTolerance {
+			out = append(out, fmt.Sprintf("%s: %s vs %s (%.1f%%)", field,
+				fmtVerifyValue(a), fmtVerifyValue(b), rel*100))
+		}
+	}
+	checkTotals := func(prefix string, a, b UsageTotals) {
+		check(prefix+"input_tokens", float64(a.InputTokens), float64(b.InputTokens))
+		check(prefix+"output_tokens", float64(a.OutputTokens), float64(b.OutputTokens))
+		check(prefix+"cache_creation_input_tokens", float64(a.CacheCreationInputTokens), float64(b.CacheCreationInputTokens))
+		check(prefix+"cache_read_input_tokens", float64(a.CacheReadInputTokens), float64(b.CacheReadInputTokens))
+		check(prefix+"cost_usd", a.CostUSD, b.CostUSD)
+		check(prefix+"message_count", float64(a.MessageCount), float64(b.MessageCount))
+	}
+
+	checkTotals("grand.", r1.Grand, r2.Grand)
+	check("session_count", float64(len(r1.Sessions)), float64(len(r2.Sessions)))
+
+	models1, models2 := make(map[string]UsageTotals), make(map[string]UsageTotals)
+	for m, t := range r1.ModelSummaries {
+		models1[m] = *t
+	}
+	for m, t := range r2.ModelSummaries {
+		models2[m] = *t
+	}
+	for _, m := range unionKeys(models1, models2) {
+		checkTotals("model "+m+": ", models1[m], models2[m])
+	}
+
+	projects1, projects2 := make(map[string]UsageTotals), make(map[string]UsageTotals)
+	for _, p := range r1.Projects {
+		projects1[p.Key()] = p.Totals
+	}
+	for _, p := range r2.Projects {
+		projects2[p.Key()] = p.Totals
+	}
+	for _, key := range unionKeys(projects1, projects2) {
+		checkTotals("project "+key+": ", projects1[key], projects2[key])
+	}
+
+	days1, days2 := make(map[string]UsageTotals), make(map[string]UsageTotals)
+	for _, d := range r1.Daily {
+		days1[d.Date] = d.Totals
+	}
+	for _, d := range r2.Daily {
+		days2[d.Date] = d.Totals
+	}
+	for _, date := range unionKeys(days1, days2) {
+		checkTotals("day "+date+": ", days1[date], days2[date])
+	}
+	return out
+}
+
+// unionKeys returns the keys present in either map, sorted.
+func unionKeys(a, b map[string]UsageTotals) []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fmtVerifyValue prints counts without decimals and costs to four places.
+func fmtVerifyValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.4f", v)
+}